@@ -0,0 +1,9 @@
+package configs
+
+// Version is the release of idk. Releases set it when building with
+// -ldflags "-X github.com/rishijash/idk_terminal/configs.Version=1.2.3"
+var Version = "dev"
+
+// ReleasePublicKey is the base64 encoded ed25519 key that release binaries are signed with, set like Version.
+// Builds without it can't verify updates and refuse to self update.
+var ReleasePublicKey = ""
@@ -4,13 +4,20 @@ import (
 	"embed"
 	"encoding/json"
 	"io/fs"
+	"os"
 )
 
 //go:embed appConfigs.json
 var configFS embed.FS // Embedding the specific file
 
+// defaultReleaseBaseUrl hosts the release manifests and binaries used by `idk self update`
+const defaultReleaseBaseUrl = "https://idk-cli.github.io/releases"
+
 type Config struct {
 	IdkBackendBaseUrl string `json:"idkBackendBaseUrl"`
+	// ReleaseBaseUrl is where `idk self update` looks for releases. IDK_RELEASE_URL overrides it,
+	// e.g. to test updates against a local release server.
+	ReleaseBaseUrl string `json:"releaseBaseUrl"`
 }
 
 func LoadConfig() (*Config, error) {
@@ -23,5 +30,11 @@ func LoadConfig() (*Config, error) {
 	if err := json.Unmarshal(data, config); err != nil {
 		return nil, err
 	}
+	if config.ReleaseBaseUrl == "" {
+		config.ReleaseBaseUrl = defaultReleaseBaseUrl
+	}
+	if releaseBaseUrl := os.Getenv("IDK_RELEASE_URL"); releaseBaseUrl != "" {
+		config.ReleaseBaseUrl = releaseBaseUrl
+	}
 	return config, nil
 }
@@ -0,0 +1,154 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UserConfig holds the settings users can change in ~/.idk/config.json
+type UserConfig struct {
+	// MaxAutoConfirmRisk is the highest risk level (low, medium, high) that --yes confirms without --yes-really
+	MaxAutoConfirmRisk string `json:"maxAutoConfirmRisk"`
+	// StrictScripts runs generated shell scripts with `set -euo pipefail`, like --strict
+	StrictScripts bool `json:"strictScripts"`
+	// SandboxImage is the container image --sandbox uses when bubblewrap is not installed
+	SandboxImage string `json:"sandboxImage"`
+	// ScriptLibraryDir is where saved scripts are kept, point it at a git checkout to share scripts with a team
+	ScriptLibraryDir string `json:"scriptLibraryDir"`
+	// Theme is the color theme: dark, light or plain. NO_COLOR turns colors off regardless.
+	Theme string `json:"theme"`
+	// UpdateChannel is the release channel `idk self update` installs from, like stable or beta
+	UpdateChannel string `json:"updateChannel"`
+	// Templates are reusable prompts invoked with `idk @<name> key=value...`
+	Templates map[string]PromptTemplate `json:"templates"`
+}
+
+// PromptTemplate is a prompt with {{key}} placeholders. In the config file it is either
+// the prompt itself or an object with the fields below.
+type PromptTemplate struct {
+	Prompt string `json:"prompt"`
+	// ActionType, when set, rejects backend answers of any other action type (COMMAND, SCRIPT or PLAN)
+	ActionType string `json:"actionType,omitempty"`
+	// Command is a known-good answer that is run without asking the backend. Its placeholders are shell quoted.
+	Command string `json:"command,omitempty"`
+}
+
+func (t *PromptTemplate) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &t.Prompt)
+	}
+
+	// the alias drops this method so the object form is decoded as usual
+	type promptTemplate PromptTemplate
+	return json.Unmarshal(data, (*promptTemplate)(t))
+}
+
+func defaultUserConfig() *UserConfig {
+	return &UserConfig{
+		MaxAutoConfirmRisk: "medium",
+		SandboxImage:       "alpine:latest",
+		Theme:              "dark",
+		UpdateChannel:      "stable",
+	}
+}
+
+// UserConfigPath returns the location of the user config file
+func UserConfigPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(homeDir, ".idk", "config.json")
+}
+
+// SettableUserConfigKeys are the settings `idk config set` can change, templates are edited in the file
+var SettableUserConfigKeys = []string{"maxAutoConfirmRisk", "strictScripts", "sandboxImage", "scriptLibraryDir", "theme", "updateChannel"}
+
+// LoadUserConfig reads the user config file. Missing files and fields fall back to the defaults.
+func LoadUserConfig() (*UserConfig, error) {
+	data, err := os.ReadFile(UserConfigPath())
+	if os.IsNotExist(err) {
+		return defaultUserConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseUserConfig(data)
+}
+
+func parseUserConfig(data []byte) (*UserConfig, error) {
+	config := defaultUserConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(config.ScriptLibraryDir, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		config.ScriptLibraryDir = filepath.Join(homeDir, config.ScriptLibraryDir[2:])
+	}
+	return config, nil
+}
+
+// SetUserConfigValue changes one setting in the user config file, keeping the other fields as they are written.
+// The file is only saved if validate accepts the resulting config.
+func SetUserConfigValue(key string, value string, validate func(*UserConfig) error) error {
+	settable := false
+	for _, settableKey := range SettableUserConfigKeys {
+		settable = settable || settableKey == key
+	}
+	if !settable {
+		return fmt.Errorf("unknown setting `%s`. Use %s", key, strings.Join(SettableUserConfigKeys, ", "))
+	}
+
+	fields := map[string]json.RawMessage{}
+	data, err := os.ReadFile(UserConfigPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+	}
+
+	var encoded []byte
+	if key == "strictScripts" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("strictScripts must be true or false")
+		}
+		encoded, err = json.Marshal(enabled)
+		if err != nil {
+			return err
+		}
+	} else {
+		encoded, err = json.Marshal(value)
+		if err != nil {
+			return err
+		}
+	}
+	fields[key] = encoded
+
+	data, err = json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return err
+	}
+	config, err := parseUserConfig(data)
+	if err != nil {
+		return err
+	}
+	if err := validate(config); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(UserConfigPath()), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(UserConfigPath(), append(data, '\n'), 0600)
+}
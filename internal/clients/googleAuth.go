@@ -11,6 +11,9 @@ import (
 	"golang.org/x/oauth2"
 )
 
+// AuthCallbackPort is the local port the login callback is served on
+const AuthCallbackPort = 7999
+
 var (
 	conf          *oauth2.Config
 	authCodeCh    = make(chan *string)
@@ -25,7 +28,7 @@ func StartAuthFlow(ctx context.Context, url string, state string) (string, error
 		callbackHandler(w, r, state)
 	})
 
-	server := &http.Server{Addr: ":7999"}
+	server := &http.Server{Addr: fmt.Sprintf(":%d", AuthCallbackPort)}
 
 	go func() {
 		if err := server.ListenAndServe(); err != http.ErrServerClosed {
@@ -65,18 +68,30 @@ func callbackHandler(w http.ResponseWriter, r *http.Request, state string) {
 	authCodeCh <- &code
 }
 
-// openBrowser tries to open the browser with a given URL.
-func openBrowser(url string) {
-	var err error
+// BrowserOpener returns the command that opens URLs in the browser, or an error if it is missing
+func BrowserOpener() ([]string, error) {
+	var command []string
 	switch runtime.GOOS {
 	case "linux":
-		err = exec.Command("xdg-open", url).Start()
+		command = []string{"xdg-open"}
 	case "darwin":
-		err = exec.Command("open", url).Start()
+		command = []string{"open"}
 	case "windows":
-		err = exec.Command("cmd", "/c", "start", url).Start()
+		command = []string{"cmd", "/c", "start"}
 	default:
-		err = fmt.Errorf("unsupported platform")
+		return nil, fmt.Errorf("unsupported platform")
+	}
+	if _, err := exec.LookPath(command[0]); err != nil {
+		return nil, fmt.Errorf("%s not found", command[0])
+	}
+	return command, nil
+}
+
+// openBrowser tries to open the browser with a given URL.
+func openBrowser(url string) {
+	command, err := BrowserOpener()
+	if err == nil {
+		err = exec.Command(command[0], append(command[1:], url)...).Start()
 	}
 	if err != nil {
 		log.Fatal(err)
@@ -6,6 +6,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rishijash/idk_terminal/internal/utils"
 )
 
 func CreateGoogleAuthCodeURL(state string, idkBackendBaseUrl string) (string, error) {
@@ -90,7 +94,43 @@ func CreateIDKToken(googleAuthCode string, idkBackendBaseUrl string) (string, er
 	return token, nil
 }
 
-func ProcessPrompt(prompt string, os string, readmeData string, existingScript string, pwd string, jwtToken string, idkBackendBaseUrl string) (*PromptResponse, error, int) {
+// ValidateIDKToken asks the backend if it accepts jwtToken. A token can look valid locally and still be
+// rejected, e.g. after it was revoked. The status is returned for callers to tell rejections from outages.
+func ValidateIDKToken(jwtToken string, idkBackendBaseUrl string, timeout time.Duration) (error, int) {
+	requestUrl := fmt.Sprintf("%s/validateToken", idkBackendBaseUrl)
+	req, err := http.NewRequest("GET", requestUrl, nil)
+	if err != nil {
+		return err, 0
+	}
+	req.Header.Set("Authorization", jwtToken)
+
+	client := &http.Client{Timeout: timeout}
+	response, err := client.Do(req)
+	if err != nil {
+		return err, 0
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned non-OK status: %d", response.StatusCode), response.StatusCode
+	}
+	return nil, response.StatusCode
+}
+
+// PingBackend measures how long the backend takes to answer a request. Any status counts as an answer,
+// so the status is returned for callers to judge.
+func PingBackend(idkBackendBaseUrl string, timeout time.Duration) (time.Duration, error, int) {
+	client := &http.Client{Timeout: timeout}
+	start := time.Now()
+	response, err := client.Get(idkBackendBaseUrl)
+	if err != nil {
+		return 0, err, 0
+	}
+	defer response.Body.Close()
+	return time.Since(start), nil, response.StatusCode
+}
+
+func ProcessPrompt(prompt string, os string, readmeData string, existingScript string, pwd string, projectContext *utils.ProjectContext, planFailure *PlanFailure, jwtToken string, idkBackendBaseUrl string) (*PromptResponse, error, int) {
 	requestBodyMap := map[string]interface{}{
 		"prompt":         prompt,
 		"os":             os,
@@ -98,6 +138,12 @@ func ProcessPrompt(prompt string, os string, readmeData string, existingScript s
 		"readmeData":     readmeData,
 		"pwd":            pwd,
 	}
+	if projectContext != nil {
+		requestBodyMap["projectContext"] = projectContext
+	}
+	if planFailure != nil {
+		requestBodyMap["planFailure"] = planFailure
+	}
 
 	requestBodyBytes, err := json.Marshal(requestBodyMap)
 	if err != nil {
@@ -133,15 +179,66 @@ func ProcessPrompt(prompt string, os string, readmeData string, existingScript s
 		return nil, err, response.StatusCode
 	}
 
+	// only PLAN responses carry steps, and only some COMMAND responses have alternatives
+	var listData struct {
+		Steps        []PlanStep           `json:"steps"`
+		Alternatives []CommandAlternative `json:"alternatives"`
+	}
+	_ = json.Unmarshal(body, &listData)
+
 	return &PromptResponse{
-		Response:   responseData["response"].(string),
-		ActionType: responseData["actionType"].(string),
+		Response:     responseData["response"].(string),
+		ActionType:   responseData["actionType"].(string),
+		Explanation:  optionalString(responseData, "explanation"),
+		Risk:         optionalString(responseData, "risk"),
+		Language:     optionalString(responseData, "language"),
+		Steps:        listData.Steps,
+		Alternatives: listData.Alternatives,
+		RequestId:    response.Header.Get("X-Request-Id"),
+		Quota:        parseQuotaInfo(response.Header),
 	}, nil, response.StatusCode
 }
 
 type PromptResponse struct {
-	Response   string
-	ActionType string
+	Response    string
+	ActionType  string
+	Explanation string
+	Risk        string
+	// Language is the language of SCRIPT responses, empty if the backend did not declare one
+	Language string
+	// Steps are the steps of PLAN responses
+	Steps []PlanStep
+	// Alternatives are other commands for COMMAND responses, ranked from best to worst
+	Alternatives []CommandAlternative
+	RequestId    string
+	Quota        *QuotaInfo
+}
+
+// CommandAlternative is another way to do what a COMMAND response does
+type CommandAlternative struct {
+	Command string `json:"command"`
+	// Note is a short trade-off, e.g. "portable" or "needs fd installed"
+	Note string `json:"note,omitempty"`
+	Risk string `json:"risk,omitempty"`
+}
+
+// PlanStep is one step of a plan for tasks that don't fit in a single command
+type PlanStep struct {
+	Description string `json:"description"`
+	Command     string `json:"command"`
+	// Precondition is a command that has to succeed before the step runs
+	Precondition string `json:"precondition,omitempty"`
+	// Verification is a command that succeeds if the step did what it should
+	Verification string `json:"verification,omitempty"`
+}
+
+// PlanFailure asks the backend for a new plan that continues from a failed step
+type PlanFailure struct {
+	Steps      []PlanStep `json:"steps"`
+	FailedStep int        `json:"failedStep"`
+	Error      string     `json:"error"`
+	// Replans counts the plans that failed before
+	Replans int `json:"replans"`
 }
 
 func ProcessDebugCommand(command string, os string, err error, jwtToken string, idkBackendBaseUrl string) (*DebugCommandResponse, error, int) {
@@ -186,21 +283,31 @@ func ProcessDebugCommand(command string, os string, err error, jwtToken string,
 	}
 
 	return &DebugCommandResponse{
-		Response: responseData["response"].(string),
+		Response:  responseData["response"].(string),
+		RequestId: response.Header.Get("X-Request-Id"),
+		Quota:     parseQuotaInfo(response.Header),
 	}, nil, response.StatusCode
 }
 
 type DebugCommandResponse struct {
-	Response string
+	Response  string
+	RequestId string
+	Quota     *QuotaInfo
 }
 
-func ProcessGetProjectInit(projectFolderName string, files []string, readmedata string, makefiledata string, os string, jwtToken string, idkBackendBaseUrl string) (*RunGetProjectInitResponse, error, int) {
+// ProcessGetProjectInit asks the backend for the commands that setup a project. packageManager is the
+// manager the commands should use and packageManagers lists every manager installed on the machine.
+// projectScan summarizes the manifests of the project and its sub-projects, files are only the top-level names.
+func ProcessGetProjectInit(projectFolderName string, files []string, readmedata string, makefiledata string, projectScan *utils.ProjectScan, os string, packageManager string, packageManagers []string, jwtToken string, idkBackendBaseUrl string) (*RunGetProjectInitResponse, error, int) {
 	requestBodyMap := map[string]interface{}{
 		"files":             files,
 		"readme":            readmedata,
 		"makefile":          makefiledata,
+		"projectScan":       projectScan,
 		"os":                os,
 		"projectFolderName": projectFolderName,
+		"packageManager":    packageManager,
+		"packageManagers":   packageManagers,
 	}
 
 	requestBodyBytes, err := json.Marshal(requestBodyMap)
@@ -236,6 +343,8 @@ func ProcessGetProjectInit(projectFolderName string, files []string, readmedata
 	if err != nil {
 		return nil, err, 0
 	}
+	runGetProjectTypeResponse.RequestId = response.Header.Get("X-Request-Id")
+	runGetProjectTypeResponse.Quota = parseQuotaInfo(response.Header)
 
 	return &runGetProjectTypeResponse, nil, response.StatusCode
 }
@@ -243,9 +352,53 @@ func ProcessGetProjectInit(projectFolderName string, files []string, readmedata
 type RunGetProjectInitResponse struct {
 	ProjectType string                     `json:"projectType"`
 	Commands    []RunGetProjectInitCommand `json:"commands"`
+	// BuildCommand and TestCommand verify the setup, they are guessed from the build system if empty
+	BuildCommand string `json:"buildCommand,omitempty"`
+	TestCommand  string `json:"testCommand,omitempty"`
+	// ReadyPort and ReadyLog tell when the run command, usually a server, has started
+	ReadyPort int        `json:"readyPort,omitempty"`
+	ReadyLog  string     `json:"readyLog,omitempty"`
+	RequestId string     `json:"-"`
+	Quota     *QuotaInfo `json:"-"`
 }
 
 type RunGetProjectInitCommand struct {
-	Command     string `json:"command"`
-	Description string `json:"description"`
+	// Id, DependsOn and Parallel let independent steps run at the same time, see utils.SetupStep
+	Id          string   `json:"id,omitempty"`
+	Command     string   `json:"command"`
+	Description string   `json:"description"`
+	DependsOn   []string `json:"dependsOn,omitempty"`
+	Parallel    bool     `json:"parallel,omitempty"`
+	// Check is a command that succeeds if the step is already satisfied, like `command -v node`
+	Check string `json:"check,omitempty"`
+}
+
+// QuotaInfo is the daily quota reported by the backend in the X-Quota-* response headers
+type QuotaInfo struct {
+	Limit     int `json:"limit"`
+	Remaining int `json:"remaining"`
+}
+
+func parseQuotaInfo(header http.Header) *QuotaInfo {
+	limit, err := strconv.Atoi(header.Get("X-Quota-Limit"))
+	if err != nil {
+		return nil
+	}
+	remaining, err := strconv.Atoi(header.Get("X-Quota-Remaining"))
+	if err != nil {
+		return nil
+	}
+	return &QuotaInfo{
+		Limit:     limit,
+		Remaining: remaining,
+	}
+}
+
+// optionalString reads a string field that older backend versions may not send
+func optionalString(responseData map[string]interface{}, key string) string {
+	value, ok := responseData[key].(string)
+	if !ok {
+		return ""
+	}
+	return value
 }
@@ -0,0 +1,87 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ReleaseManifest lists the releases of one channel, like stable or beta. It is served as <channel>.json.
+type ReleaseManifest struct {
+	Channel  string    `json:"channel"`
+	Releases []Release `json:"releases"`
+}
+
+type Release struct {
+	Version  string          `json:"version"`
+	Date     string          `json:"date,omitempty"`
+	Notes    string          `json:"notes,omitempty"`
+	Binaries []ReleaseBinary `json:"binaries"`
+}
+
+// ReleaseBinary is the executable of a release for one platform
+type ReleaseBinary struct {
+	Os   string `json:"os"`
+	Arch string `json:"arch"`
+	// Url is absolute or relative to the manifest
+	Url    string `json:"url"`
+	Sha256 string `json:"sha256"`
+	// Signature is the base64 encoded ed25519 signature of utils.ReleaseSignedMessage for the version
+	// of the release, this platform and Sha256
+	Signature string `json:"signature"`
+}
+
+// FetchReleaseManifest downloads the manifest of a release channel. The timeout keeps update checks
+// from blocking commands like `idk version` when the release server is unreachable.
+func FetchReleaseManifest(releaseBaseUrl string, channel string, timeout time.Duration) (*ReleaseManifest, error) {
+	client := &http.Client{Timeout: timeout}
+	response, err := client.Get(fmt.Sprintf("%s/%s.json", releaseBaseUrl, url.PathEscape(channel)))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("release channel `%s` does not exist", channel)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release server returned non-OK status: %d", response.StatusCode)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest ReleaseManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid release manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+// DownloadReleaseBinary downloads a binary listed in the manifest of channel. It is not verified yet.
+func DownloadReleaseBinary(releaseBaseUrl string, channel string, binary ReleaseBinary) ([]byte, error) {
+	manifestUrl, err := url.Parse(fmt.Sprintf("%s/%s.json", releaseBaseUrl, url.PathEscape(channel)))
+	if err != nil {
+		return nil, err
+	}
+	binaryUrl, err := manifestUrl.Parse(binary.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := http.Get(binaryUrl.String())
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release server returned non-OK status: %d", response.StatusCode)
+	}
+	return io.ReadAll(response.Body)
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/term"
+
+	"github.com/rishijash/idk_terminal/internal/output"
+)
+
+// choose lets the user pick one of items and returns its index, or -1 if the choice was canceled.
+// Terminals get an arrow key menu, anything else a numbered list. With --yes the first item is picked.
+func (o Options) choose(title string, items []string) int {
+	if o.AutoConfirm {
+		output.Printf("%s: %s (--yes)\n", title, items[0])
+		return 0
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return chooseNumbered(title, items)
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return chooseNumbered(title, items)
+	}
+	defer term.Restore(fd, oldState)
+
+	output.Printf("%s (arrows or j/k to move, enter to pick, q to cancel):\r\n", title)
+	selected := 0
+	drawChoices(items, selected, false)
+	for {
+		key, err := stdinReader.ReadByte()
+		if err != nil {
+			return -1
+		}
+
+		switch key {
+		case '\r', '\n':
+			return selected
+		case 'q', 3, 4: // q, ctrl-c, ctrl-d
+			return -1
+		case 'k':
+			selected = (selected + len(items) - 1) % len(items)
+		case 'j':
+			selected = (selected + 1) % len(items)
+		case 27: // arrow keys are sent as ESC [ A (up) and ESC [ B (down)
+			if next, _ := stdinReader.ReadByte(); next != '[' {
+				return -1
+			}
+			switch arrow, _ := stdinReader.ReadByte(); arrow {
+			case 'A':
+				selected = (selected + len(items) - 1) % len(items)
+			case 'B':
+				selected = (selected + 1) % len(items)
+			}
+		default:
+			if number, err := strconv.Atoi(string(key)); err == nil && number >= 1 && number <= len(items) {
+				selected = number - 1
+			}
+		}
+		drawChoices(items, selected, true)
+	}
+}
+
+// drawChoices prints items with a marker on the selected one, redraw overwrites the previous menu.
+// The terminal is in raw mode, so lines end with \r\n.
+func drawChoices(items []string, selected int, redraw bool) {
+	if redraw {
+		output.Printf("\x1b[%dA", len(items))
+	}
+	for i, item := range items {
+		marker := "  "
+		if i == selected {
+			marker = "> "
+		}
+		output.Printf("\r\x1b[2K%s%d. %s\r\n", marker, i+1, item)
+	}
+}
+
+func chooseNumbered(title string, items []string) int {
+	output.Printf("%s:\n", title)
+	for i, item := range items {
+		output.Printf("  %d. %s\n", i+1, item)
+	}
+	output.Printf("Pick a number (1-%d, anything else cancels): ", len(items))
+
+	number, err := strconv.Atoi(readLine())
+	if err != nil || number < 1 || number > len(items) {
+		return -1
+	}
+	return number - 1
+}
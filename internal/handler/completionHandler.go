@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/rishijash/idk_terminal/configs"
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+type CompletionHandler struct {
+	options Options
+}
+
+func NewCompletionHandler(options Options) CompletionHandler {
+	return CompletionHandler{
+		options: options,
+	}
+}
+
+// HandleCompletion prints the completion script of shell for the commands described by root
+func (h CompletionHandler) HandleCompletion(ctx context.Context, shell string, root utils.CompletionCommand) error {
+	switch shell {
+	case "bash":
+		output.Printf("%s", utils.BashCompletion(root.Name, root))
+	case "zsh":
+		output.Printf("%s", utils.ZshCompletion(root.Name, root))
+	case "fish":
+		output.Printf("%s", utils.FishCompletion(root.Name, root))
+	default:
+		return NewError("invalid_input", "Unsupported shell `"+shell+"`. Use bash, zsh or fish")
+	}
+	return nil
+}
+
+// HandleComplete prints one candidate per line for the generated completion scripts.
+// words are the words typed after the command, without the word being completed.
+func (h CompletionHandler) HandleComplete(ctx context.Context, kind string, words []string, templates map[string]configs.PromptTemplate) {
+	var candidates []string
+	switch kind {
+	case utils.CompletePrompt:
+		var typed []string
+		for _, word := range words {
+			if !strings.HasPrefix(word, "-") {
+				typed = append(typed, word)
+			}
+		}
+		if len(typed) == 0 {
+			for name := range templates {
+				candidates = append(candidates, "@"+name)
+			}
+			sort.Strings(candidates)
+		}
+
+		entries, _ := utils.LoadHistory()
+		var prompts []string
+		for _, entry := range entries {
+			if entry.Prompt != "" {
+				prompts = append(prompts, entry.Prompt)
+			}
+		}
+		candidates = append(candidates, utils.PromptCompletions(prompts, typed)...)
+	case utils.CompleteScript:
+		scripts, _ := utils.ListLibraryScripts(h.options.ScriptLibraryDir, "")
+		for _, script := range scripts {
+			candidates = append(candidates, script.Name)
+		}
+	case utils.CompleteSnapshot:
+		snapshots, _ := utils.ListSnapshots()
+		for _, snapshot := range snapshots {
+			candidates = append(candidates, snapshot.Id)
+		}
+	}
+
+	for _, candidate := range candidates {
+		output.Println(candidate)
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rishijash/idk_terminal/configs"
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+type ConfigHandler struct {
+	userConfig *configs.UserConfig
+	options    Options
+}
+
+func NewConfigHandler(userConfig *configs.UserConfig, options Options) ConfigHandler {
+	return ConfigHandler{
+		userConfig: userConfig,
+		options:    options,
+	}
+}
+
+// HandleShow prints every setting, including the defaults of settings missing from the config file
+func (h ConfigHandler) HandleShow(ctx context.Context) {
+	utils.PrintJson(h.userConfig)
+}
+
+func (h ConfigHandler) HandlePath(ctx context.Context) {
+	output.Println(configs.UserConfigPath())
+}
+
+func (h ConfigHandler) HandleGet(ctx context.Context, key string) error {
+	data, err := json.Marshal(h.userConfig)
+	if err != nil {
+		return NewError("internal_error", "Something went wrong. Please try again!")
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return NewError("internal_error", "Something went wrong. Please try again!")
+	}
+
+	value, ok := fields[key]
+	if !ok {
+		return NewError("invalid_input", fmt.Sprintf("Unknown setting `%s`", key))
+	}
+	var text string
+	if json.Unmarshal(value, &text) == nil {
+		output.Println(text)
+		return nil
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, value, "", "  "); err != nil {
+		output.Println(string(value))
+		return nil
+	}
+	output.Println(indented.String())
+	return nil
+}
+
+// HandleSet saves one setting to the config file if validate accepts the config with the new value
+func (h ConfigHandler) HandleSet(ctx context.Context, key string, value string, validate func(*configs.UserConfig) error) error {
+	if err := configs.SetUserConfigValue(key, value, validate); err != nil {
+		return NewError("invalid_input", fmt.Sprintf("Could not set %s: %s", key, err))
+	}
+	output.Println(fmt.Sprintf("Set %s to %s in %s", key, value, configs.UserConfigPath()))
+	return nil
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+// confirm asks the user to pick one of choices and returns the answer in lower case.
+// With --yes the first choice is picked without asking, unless risk is above the
+// configured limit and --yes-really was not given. In that case an empty answer is returned.
+func (o Options) confirm(question string, choices []string, risk string) string {
+	if o.AutoConfirm {
+		if utils.IsRiskAbove(risk, o.MaxAutoConfirmRisk) && !o.AutoConfirmAnyRisk {
+			output.Warnln(fmt.Sprintf("Refusing to auto-confirm a %s risk action. Use --yes-really to run it anyway", risk))
+			return ""
+		}
+		output.Printf("%s (%s): %s (--yes)\n", question, strings.Join(choices, "/"), choices[0])
+		return choices[0]
+	}
+
+	output.Printf("%s (%s): ", question, strings.Join(choices, "/"))
+	return strings.ToLower(readLine())
+}
+
+// ask reads a free text answer to question
+func (o Options) ask(question string) string {
+	output.Println(question)
+	return readLine()
+}
+
+// stdinReader is shared by all questions so buffered answers are not lost between them
+var stdinReader = bufio.NewReader(os.Stdin)
+
+func readLine() string {
+	response, _ := stdinReader.ReadString('\n')
+	return strings.TrimSpace(response) // Trim whitespace and newline character
+}
+
+// printRisk warns the user before confirming anything that is not low risk
+func printRisk(risk string) {
+	if risk != utils.RiskLow && utils.IsValidRisk(risk) {
+		output.Printf("Risk: %s\n", output.Risk(risk))
+	}
+}
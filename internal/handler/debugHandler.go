@@ -1,85 +1,104 @@
 package handler
 
 import (
-	"bufio"
 	"context"
 	"fmt"
-	"net/http"
 	"os"
 	"runtime"
-	"strings"
-	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/rishijash/idk_terminal/configs"
 	"github.com/rishijash/idk_terminal/internal/clients"
+	"github.com/rishijash/idk_terminal/internal/output"
 	"github.com/rishijash/idk_terminal/internal/utils"
 )
 
 type DebugHandler struct {
-	config *configs.Config
+	config  *configs.Config
+	options Options
 }
 
-func NewDebugHandler(config *configs.Config) DebugHandler {
+func NewDebugHandler(config *configs.Config, options Options) DebugHandler {
 	return DebugHandler{
-		config: config,
+		config:  config,
+		options: options,
 	}
 }
 
-func (h DebugHandler) HandleCommandDebug(ctx context.Context, command string) {
-	token, err := utils.LoadToken()
+// debugResult is the JSON object printed for debugged commands in JSON output mode
+type debugResult struct {
+	Command      string             `json:"command"`
+	Failed       bool               `json:"failed"`
+	CommandError string             `json:"commandError,omitempty"`
+	Explanation  string             `json:"explanation,omitempty"`
+	RequestId    string             `json:"requestId,omitempty"`
+	Quota        *clients.QuotaInfo `json:"quota,omitempty"`
+}
+
+// HandleCommandDebug runs command and explains its failure. A failed command passes its exit code through.
+func (h DebugHandler) HandleCommandDebug(ctx context.Context, command string) error {
+	token, err := loadToken()
 	if err != nil {
-		println("You are not logged in. Please login first")
-		println("Command: `idk --login`")
-		return
+		return err
+	}
+
+	risk := utils.AssessCommandRisk(command)
+	if h.options.IsJsonOutput() {
+		// the command was given explicitly, so it runs without asking, but only up to the same limit as --yes
+		if utils.IsRiskAbove(risk, h.options.MaxAutoConfirmRisk) && !h.options.AutoConfirmAnyRisk {
+			return NewError("cancelled", fmt.Sprintf("Refusing to run a %s risk command without asking", risk), "Use --yes-really to run it anyway")
+		}
+		// stdout is kept for the JSON result
+		err = utils.RunCommandWithOutput(command, os.Stderr)
+		if err == nil {
+			utils.PrintJson(debugResult{Command: command})
+			return nil
+		}
+		return h.commandDebugAction(command, err, token)
 	}
 
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("This will execute command `%s` and help debug the result", command)
-	println("")
-	fmt.Printf("Continue? (y/n)")
-	response, _ := reader.ReadString('\n')
-	response = strings.TrimSpace(response) // Trim whitespace and newline character
+	output.Printf("This will execute command `%s` and help debug the result\n", command)
+	printRisk(risk)
+	response := h.options.confirm("Continue?", []string{"y", "n"}, risk)
 
-	if strings.ToLower(response) == "y" {
+	if response == "y" {
 		err = utils.RunCommand(command)
 	} else {
-		fmt.Println("Command execution canceled")
-		return
+		output.Println("Command execution canceled")
+		return errCancelled
 	}
 
 	if err != nil {
-		h.commandDebugAction(command, err, token)
-	} else {
-		utils.PrintMessage("No errors found in the execution")
+		return h.commandDebugAction(command, err, token)
 	}
+	output.Box("No errors found in the execution")
+	return nil
 }
 
-func (h DebugHandler) commandDebugAction(command string, err error, token string) {
-	fmt.Println("Analyzing Error..")
-	customCharset := []string{"-", "\\", "|", "/", "-", ".", "o", "O", "0", "@"}
-	loadingSpinner := spinner.New(customCharset, 100*time.Millisecond)
-	loadingSpinner.Start()
+func (h DebugHandler) commandDebugAction(command string, err error, token string) error {
+	loadingSpinner := h.options.startSpinner("Analyzing Error..")
 
-	debugResponse, err, responseStatus := clients.ProcessDebugCommand(command, runtime.GOOS, err, token, h.config.IdkBackendBaseUrl)
+	debugResponse, debugErr, responseStatus := clients.ProcessDebugCommand(command, runtime.GOOS, err, token, h.config.IdkBackendBaseUrl)
 	loadingSpinner.Stop()
 
-	if responseStatus == http.StatusUnauthorized {
-		utils.ClearToken()
-		fmt.Println("Token expired. Please login again")
-		println("Command: `idk --login`")
-		return
+	if err := responseError(responseStatus, debugErr); err != nil {
+		return err
 	}
 
-	if responseStatus == http.StatusTooManyRequests {
-		fmt.Println("Daily Quota limit reached. Plesae try again tomorrow or upgrade on https://idk-cli.github.io/")
-		return
-	}
+	// the explanation is the output, so only the exit code of the command is passed on
+	commandErr := &Error{Code: "command_failed", ExitCode: utils.ExitCode(err)}
 
-	if err != nil {
-		fmt.Println("Something went wrong. Please try again!")
-		return
+	if h.options.IsJsonOutput() {
+		utils.PrintJson(debugResult{
+			Command:      command,
+			Failed:       true,
+			CommandError: err.Error(),
+			Explanation:  debugResponse.Response,
+			RequestId:    debugResponse.RequestId,
+			Quota:        debugResponse.Quota,
+		})
+		return commandErr
 	}
 
-	utils.PrintMessage(debugResponse.Response)
+	output.Box(output.Markdown(debugResponse.Response))
+	return commandErr
 }
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rishijash/idk_terminal/configs"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+func TestHandleCommandDebugJsonRiskLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  func(dir string) string
+		anyRisk  bool
+		wantCode int
+		wantRun  bool
+	}{
+		{"high risk refused", func(dir string) string {
+			return fmt.Sprintf("touch %s && rm -rf %s", filepath.Join(dir, "ran"), filepath.Join(dir, "cache"))
+		}, false, ExitCancelled, false},
+		{"high risk with --yes-really", func(dir string) string {
+			return fmt.Sprintf("touch %s && rm -rf %s", filepath.Join(dir, "ran"), filepath.Join(dir, "cache"))
+		}, true, ExitOk, true},
+		{"low risk", func(dir string) string {
+			return fmt.Sprintf("cd %s && ls ran || true", dir)
+		}, false, ExitOk, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			if err := utils.SaveToken("header.e30.signature"); err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			options := Options{OutputFormat: OutputJson, MaxAutoConfirmRisk: utils.RiskMedium, AutoConfirmAnyRisk: test.anyRisk}
+			h := NewDebugHandler(&configs.Config{}, options)
+
+			err := h.HandleCommandDebug(context.Background(), test.command(dir))
+			if code := ExitCode(err); code != test.wantCode {
+				t.Errorf("HandleCommandDebug() exit code = %d (%v), want %d", code, err, test.wantCode)
+			}
+			if _, statErr := os.Stat(filepath.Join(dir, "ran")); (statErr == nil) != test.wantRun {
+				t.Errorf("the command ran = %v, want %v", statErr == nil, test.wantRun)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rishijash/idk_terminal/configs"
+	"github.com/rishijash/idk_terminal/internal/clients"
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// slowBackendLatency is the backend response time above which prompts feel slow
+const slowBackendLatency = 2 * time.Second
+
+// loginExpiryWarning warns about logins that expire soon
+const loginExpiryWarning = 72 * time.Hour
+
+type DoctorHandler struct {
+	config  *configs.Config
+	options Options
+}
+
+func NewDoctorHandler(config *configs.Config, options Options) DoctorHandler {
+	return DoctorHandler{
+		config:  config,
+		options: options,
+	}
+}
+
+// doctorCheck is the result of one check, Fix tells users how to resolve a warning or failure
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+// doctorResult is the JSON object printed by `idk doctor` in JSON output mode, meant to be attached to bug reports
+type doctorResult struct {
+	Version string        `json:"version"`
+	Os      string        `json:"os"`
+	Arch    string        `json:"arch"`
+	Checks  []doctorCheck `json:"checks"`
+}
+
+// HandleDoctor checks everything idk depends on and prints the results. validate checks the
+// settings of the user config. Failed checks make it return an error that was already reported.
+func (h DoctorHandler) HandleDoctor(ctx context.Context, validate func(*configs.UserConfig) error) error {
+	loadingSpinner := h.options.startSpinner("Checking your environment..")
+	checks := []doctorCheck{
+		h.checkBackend(),
+		h.checkCredentials(),
+		checkConfig(validate),
+		checkIdkDir(),
+		checkClipboard(),
+		checkBrowser(),
+		checkCallbackPort(),
+		checkShell(),
+	}
+	loadingSpinner.Stop()
+
+	failures, warnings := 0, 0
+	for _, check := range checks {
+		switch check.Status {
+		case checkFail:
+			failures++
+		case checkWarn:
+			warnings++
+		}
+	}
+
+	if h.options.IsJsonOutput() {
+		utils.PrintJson(doctorResult{Version: configs.Version, Os: runtime.GOOS, Arch: runtime.GOARCH, Checks: checks})
+	} else {
+		output.Printf("idk %s (%s/%s)\n\n", configs.Version, runtime.GOOS, runtime.GOARCH)
+		for _, check := range checks {
+			output.Printf("%s  %-12s %s\n", output.Status(check.Status), check.Name, check.Message)
+			if check.Fix != "" {
+				output.Printf("      %-12s %s\n", "", check.Fix)
+			}
+		}
+		output.Printf("\n%d failed, %d warnings\n", failures, warnings)
+	}
+
+	if failures > 0 {
+		return NewError("doctor_failed")
+	}
+	return nil
+}
+
+func (h DoctorHandler) checkBackend() doctorCheck {
+	check := doctorCheck{Name: "Backend"}
+	latency, err, status := clients.PingBackend(h.config.IdkBackendBaseUrl, 10*time.Second)
+	switch {
+	case err != nil:
+		check.Status = checkFail
+		check.Message = fmt.Sprintf("%s is unreachable: %s", h.config.IdkBackendBaseUrl, err)
+		check.Fix = "Check your internet connection, proxy and firewall settings"
+	case status >= http.StatusInternalServerError:
+		check.Status = checkFail
+		check.Message = fmt.Sprintf("%s answered with status %d in %s", h.config.IdkBackendBaseUrl, status, latency.Round(time.Millisecond))
+		check.Fix = "The backend has problems, please try again later"
+	case latency > slowBackendLatency:
+		check.Status = checkWarn
+		check.Message = fmt.Sprintf("%s answered in %s", h.config.IdkBackendBaseUrl, latency.Round(time.Millisecond))
+		check.Fix = "Prompts will be slow, check your connection"
+	default:
+		check.Status = checkPass
+		check.Message = fmt.Sprintf("%s answered in %s", h.config.IdkBackendBaseUrl, latency.Round(time.Millisecond))
+	}
+	return check
+}
+
+// checkCredentials checks the saved login locally, then asks the backend if it still accepts it
+func (h DoctorHandler) checkCredentials() doctorCheck {
+	check := doctorCheck{Name: "Login"}
+	token, err := utils.LoadToken()
+	if err != nil {
+		check.Status = checkFail
+		check.Message = "Not logged in"
+		check.Fix = "Login with `idk auth login`"
+		return check
+	}
+	claims, err := utils.ParseTokenClaims(token)
+	if err != nil {
+		check.Status = checkFail
+		check.Message = "The saved credentials are invalid: " + err.Error()
+		check.Fix = "Login again with `idk auth login`"
+		return check
+	}
+
+	who := "Logged in"
+	if claims.Email != "" {
+		who = "Logged in as " + claims.Email
+	}
+	switch {
+	case claims.ExpiresAt.IsZero():
+		check.Status = checkPass
+		check.Message = who
+	case claims.ExpiresAt.Before(time.Now()):
+		check.Status = checkFail
+		check.Message = "The login expired on " + claims.ExpiresAt.Format(time.RFC1123)
+		check.Fix = "Login again with `idk auth login`"
+	case time.Until(claims.ExpiresAt) < loginExpiryWarning:
+		check.Status = checkWarn
+		check.Message = who + ", the login expires on " + claims.ExpiresAt.Format(time.RFC1123)
+		check.Fix = "Login again with `idk auth login` to avoid interruptions"
+	default:
+		check.Status = checkPass
+		check.Message = who + " until " + claims.ExpiresAt.Format(time.RFC1123)
+	}
+	if check.Status == checkFail {
+		return check
+	}
+
+	err, status := clients.ValidateIDKToken(token, h.config.IdkBackendBaseUrl, 10*time.Second)
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		check.Status = checkFail
+		check.Message = "The backend rejected the saved credentials, they were revoked or belong to another backend"
+		check.Fix = "Login again with `idk auth login`"
+	case err != nil:
+		// an unreachable backend is reported by the backend check
+		check.Status = checkWarn
+		check.Message = who + ", but the backend could not confirm the login: " + err.Error()
+		check.Fix = "Check the backend, then run `idk doctor` again"
+	}
+	return check
+}
+
+func checkConfig(validate func(*configs.UserConfig) error) doctorCheck {
+	check := doctorCheck{Name: "Config"}
+	userConfig, err := configs.LoadUserConfig()
+	if err == nil {
+		err = validate(userConfig)
+	}
+	if err != nil {
+		check.Status = checkFail
+		check.Message = fmt.Sprintf("%s is invalid: %s", configs.UserConfigPath(), err)
+		check.Fix = "Fix the file or change settings with `idk config set <key> <value>`"
+		return check
+	}
+	check.Status = checkPass
+	check.Message = configs.UserConfigPath() + " is valid"
+	return check
+}
+
+func checkIdkDir() doctorCheck {
+	check := doctorCheck{Name: "Data dir"}
+	dir := utils.GetAbsoluteHomeDirectoryPath([]string{".idk"})
+	if err := utils.CheckWritableDir(dir); err != nil {
+		check.Status = checkFail
+		check.Message = fmt.Sprintf("Can't write to %s: %s", dir, err)
+		check.Fix = fmt.Sprintf("idk keeps credentials, history and snapshots there, make it writable with `chmod u+rwx %s`", dir)
+		return check
+	}
+	check.Status = checkPass
+	check.Message = dir + " is writable"
+	return check
+}
+
+func checkClipboard() doctorCheck {
+	check := doctorCheck{Name: "Clipboard"}
+	tool, err := utils.ClipboardTool()
+	if err != nil {
+		check.Status = checkWarn
+		check.Message = "--copy can't reach the clipboard, " + err.Error()
+		check.Fix = "Install xclip, xsel or wl-clipboard with your package manager"
+		return check
+	}
+	check.Status = checkPass
+	check.Message = "Using " + tool
+	return check
+}
+
+func checkBrowser() doctorCheck {
+	check := doctorCheck{Name: "Browser"}
+	command, err := clients.BrowserOpener()
+	if err != nil {
+		check.Status = checkWarn
+		check.Message = "`idk auth login` can't open the browser, " + err.Error()
+		check.Fix = "Install xdg-utils with your package manager"
+		return check
+	}
+	check.Status = checkPass
+	check.Message = "Opening login pages with " + strings.Join(command, " ")
+	return check
+}
+
+func checkCallbackPort() doctorCheck {
+	check := doctorCheck{Name: "Login port"}
+	if err := utils.IsPortFree(clients.AuthCallbackPort); err != nil {
+		check.Status = checkWarn
+		check.Message = fmt.Sprintf("Port %d is in use, `idk auth login` needs it for the login callback", clients.AuthCallbackPort)
+		check.Fix = fmt.Sprintf("Stop the program listening on it, find it with `lsof -i :%d`", clients.AuthCallbackPort)
+		return check
+	}
+	check.Status = checkPass
+	check.Message = fmt.Sprintf("Port %d is free", clients.AuthCallbackPort)
+	return check
+}
+
+func checkShell() doctorCheck {
+	check := doctorCheck{Name: "Shell"}
+	name, path := utils.DetectShell()
+	if name == "" {
+		check.Status = checkWarn
+		check.Message = "Could not detect your shell, SHELL is not set"
+		check.Fix = "Set SHELL to the path of your shell"
+		return check
+	}
+
+	check.Message = name
+	if path != "" && path != name {
+		check.Message = fmt.Sprintf("%s (%s)", name, path)
+	}
+	switch name {
+	case "bash", "zsh", "fish":
+		check.Status = checkPass
+	default:
+		check.Status = checkWarn
+		check.Fix = "Completion is only available for bash, zsh and fish"
+	}
+	return check
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rishijash/idk_terminal/configs"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+func TestCheckCredentials(t *testing.T) {
+	payload := fmt.Sprintf(`{"email":"dev@example.com","exp":%d}`, time.Now().Add(30*24*time.Hour).Unix())
+	token := "header." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".signature"
+
+	tests := []struct {
+		name       string
+		token      string
+		status     int
+		wantStatus string
+	}{
+		{"accepted", token, http.StatusOK, checkPass},
+		{"rejected", token, http.StatusUnauthorized, checkFail},
+		{"forbidden", token, http.StatusForbidden, checkFail},
+		{"backend error", token, http.StatusInternalServerError, checkWarn},
+		{"not logged in", "", http.StatusOK, checkFail},
+		{"malformed", "not-a-jwt", http.StatusOK, checkFail},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			if test.token != "" {
+				if err := utils.SaveToken(test.token); err != nil {
+					t.Fatal(err)
+				}
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/validateToken" || r.Header.Get("Authorization") != test.token {
+					http.Error(w, "unexpected request", http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			h := NewDoctorHandler(&configs.Config{IdkBackendBaseUrl: server.URL}, Options{})
+			if check := h.checkCredentials(); check.Status != test.wantStatus {
+				t.Errorf("checkCredentials() = %+v, want status %s", check, test.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+// Exit codes of idk. Commands and scripts that idk runs and that fail pass their own exit code through.
+const (
+	ExitOk    = 0
+	ExitError = 1
+	// ExitUsage is returned for invalid arguments and input
+	ExitUsage       = 2
+	ExitNotLoggedIn = 3
+	ExitQuota       = 4
+	ExitBackend     = 5
+	// ExitCancelled is returned when the user, or --yes above maxAutoConfirmRisk, declines to run something
+	ExitCancelled = 6
+)
+
+// errorExitCodes maps the error codes of JSON output to exit codes, other codes exit with ExitError
+var errorExitCodes = map[string]int{
+	"invalid_input":          ExitUsage,
+	"not_logged_in":          ExitNotLoggedIn,
+	"token_expired":          ExitNotLoggedIn,
+	"quota_exceeded":         ExitQuota,
+	"backend_error":          ExitBackend,
+	"unexpected_action_type": ExitBackend,
+	"cancelled":              ExitCancelled,
+}
+
+// Error is a failure returned by handlers. Main reports it with Options.PrintError and exits with ExitCode.
+type Error struct {
+	// Code is the machine readable error of JSON output, like not_logged_in
+	Code     string
+	Messages []string
+	ExitCode int
+}
+
+func (e *Error) Error() string {
+	if len(e.Messages) == 0 {
+		return e.Code
+	}
+	return strings.Join(e.Messages, ". ")
+}
+
+// NewError returns the failure code with messages for users, its exit code is picked from the code
+func NewError(code string, messages ...string) *Error {
+	exitCode, ok := errorExitCodes[code]
+	if !ok {
+		exitCode = ExitError
+	}
+	return &Error{Code: code, Messages: messages, ExitCode: exitCode}
+}
+
+// errCancelled is returned when running something was declined, the handler already said so
+var errCancelled = NewError("cancelled")
+
+// commandFailed reports a command or script that idk ran and that failed, passing its exit code through
+func commandFailed(message string, err error) *Error {
+	return &Error{Code: "command_failed", Messages: []string{message + ": " + err.Error()}, ExitCode: utils.ExitCode(err)}
+}
+
+// ExitCode returns the exit code for an error returned by a handler
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOk
+	}
+	var handlerErr *Error
+	if errors.As(err, &handlerErr) {
+		return handlerErr.ExitCode
+	}
+	return ExitError
+}
+
+// ReportError prints an error returned by a handler. Errors without messages were already reported.
+func (o Options) ReportError(err error) {
+	var handlerErr *Error
+	if !errors.As(err, &handlerErr) {
+		handlerErr = NewError("internal_error", err.Error())
+	}
+	if len(handlerErr.Messages) == 0 {
+		return
+	}
+	o.PrintError(handlerErr.Code, handlerErr.Messages...)
+}
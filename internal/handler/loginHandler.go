@@ -55,3 +55,12 @@ func (h LoginHandler) HandleLoginVerification(ctx context.Context) error {
 func (h LoginHandler) HandleLogout(ctx context.Context) error {
 	return utils.ClearToken()
 }
+
+// HandleLoginStatus returns the claims of the saved token, or an error if nobody is logged in
+func (h LoginHandler) HandleLoginStatus(ctx context.Context) (*utils.TokenClaims, error) {
+	token, err := utils.LoadToken()
+	if err != nil {
+		return nil, err
+	}
+	return utils.ParseTokenClaims(token)
+}
@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/fatih/color"
+
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+const (
+	OutputText = "text"
+	OutputJson = "json"
+)
+
+// Options are the command line switches and user settings that change how handlers behave
+type Options struct {
+	OutputFormat string
+	// AutoConfirm answers every confirmation with its first choice (--yes)
+	AutoConfirm bool
+	// AutoConfirmAnyRisk lets AutoConfirm run actions above MaxAutoConfirmRisk (--yes-really)
+	AutoConfirmAnyRisk bool
+	// MaxAutoConfirmRisk is the highest risk level AutoConfirm accepts on its own
+	MaxAutoConfirmRisk string
+	// PrintOnly prints generated commands and scripts instead of running them (--print-only)
+	PrintOnly bool
+	// CopyOnly copies generated commands and scripts to the clipboard instead of running them (--copy)
+	CopyOnly bool
+	// StrictScripts runs shell scripts with `set -euo pipefail` (--strict)
+	StrictScripts bool
+	// ScriptTimeout stops scripts running longer than this, 0 means no timeout (--timeout)
+	ScriptTimeout time.Duration
+	// SandboxBackend runs generated commands and scripts in bwrap, podman or docker when set (--sandbox)
+	SandboxBackend string
+	// SandboxImage is the container image used by the podman and docker sandboxes
+	SandboxImage string
+	// ScriptLibraryDir is where saved scripts are kept
+	ScriptLibraryDir string
+}
+
+// IsJsonOutput checks if handlers should emit a single JSON object instead of interactive output
+func (o Options) IsJsonOutput() bool {
+	return o.OutputFormat == OutputJson
+}
+
+// executor returns where generated commands and scripts run. In a sandbox the changes are
+// shown once it finishes, and applying them is confirmed with the risk of what was run.
+func (o Options) executor(risk string) utils.Executor {
+	if o.SandboxBackend == "" {
+		return utils.HostExecutor{}
+	}
+
+	return utils.SandboxExecutor{
+		Backend: o.SandboxBackend,
+		Image:   o.SandboxImage,
+		Review: func(changes []utils.FileChange) bool {
+			if len(changes) == 0 {
+				output.Println("No files were changed in the sandbox")
+				return false
+			}
+			output.Println("Changes made in the sandbox:")
+			for _, change := range changes {
+				output.Printf("  %s %s\n", strings.ToUpper(change.Kind[:1]), change.Path)
+			}
+			if o.confirm("Apply these changes to your project?", []string{"y", "n"}, risk) != "y" {
+				output.Println("Sandbox changes discarded")
+				return false
+			}
+			output.Println("Applying the changes to your project")
+			return true
+		},
+	}
+}
+
+// runRisk is the risk of running an action in the current mode. Sandboxed runs can't change
+// the project on their own, their risk is checked when the changes are applied instead.
+func (o Options) runRisk(risk string) string {
+	if o.SandboxBackend != "" {
+		return utils.RiskLow
+	}
+	return risk
+}
+
+// errorResult is the JSON object printed for failures in JSON output mode
+type errorResult struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// PrintError reports a failure. In text mode every message is printed on its own line,
+// in JSON mode the first message is emitted together with a machine readable code.
+func (o Options) PrintError(code string, messages ...string) {
+	if o.IsJsonOutput() {
+		message := ""
+		if len(messages) > 0 {
+			message = messages[0]
+		}
+		utils.PrintJson(errorResult{Error: code, Message: message})
+		return
+	}
+
+	for _, message := range messages {
+		output.Errorln(message)
+	}
+}
+
+// startSpinner shows the loading spinner with an optional title. Nothing is shown in JSON mode.
+func (o Options) startSpinner(title string) *spinner.Spinner {
+	customCharset := []string{"-", "\\", "|", "/", "-", ".", "o", "O", "0", "@"}
+	loadingSpinner := spinner.New(customCharset, 100*time.Millisecond)
+	// stdout only carries the result in these modes
+	if o.IsJsonOutput() || o.PrintOnly {
+		return loadingSpinner
+	}
+
+	// the spinner colors its frames through fatih/color, which doesn't know about NO_COLOR
+	if !output.ColorEnabled(os.Stdout) {
+		color.NoColor = true
+	}
+	if title != "" {
+		output.Println(title)
+	}
+	loadingSpinner.Start()
+	return loadingSpinner
+}
+
+// responseError returns the error of a failed backend call, or nil if the call succeeded
+func responseError(responseStatus int, err error) error {
+	code, messages := backendError(responseStatus, err)
+	if code == "" {
+		return nil
+	}
+	return NewError(code, messages...)
+}
+
+// backendError returns the error code and messages for a failed backend call, or an empty
+// code if the call succeeded. Expired tokens are cleared.
+func backendError(responseStatus int, err error) (string, []string) {
+	if responseStatus == http.StatusUnauthorized {
+		utils.ClearToken()
+		return "token_expired", []string{"Token expired. Please login again", "Command: `idk auth login`"}
+	}
+
+	if responseStatus == http.StatusTooManyRequests {
+		return "quota_exceeded", []string{"Daily Quota limit reached. Plesae try again tomorrow or upgrade on https://idk-cli.github.io/"}
+	}
+
+	if err != nil {
+		return "backend_error", []string{"Something went wrong. Please try again!"}
+	}
+
+	return "", nil
+}
+
+// loadToken loads the saved credentials, the error tells the user to login
+func loadToken() (string, error) {
+	token, err := utils.LoadToken()
+	if err != nil {
+		return "", NewError("not_logged_in", "You are not logged in. Please login first", "Command: `idk auth login`")
+	}
+	return token, nil
+}
@@ -1,189 +1,465 @@
 package handler
 
 import (
-	"bufio"
 	"fmt"
-	"net/http"
 	"os"
 	"runtime"
+	"slices"
 	"strings"
-	"time"
 
 	"github.com/atotto/clipboard"
-	"github.com/briandowns/spinner"
 
 	"github.com/rishijash/idk_terminal/configs"
 	"github.com/rishijash/idk_terminal/internal/clients"
+	"github.com/rishijash/idk_terminal/internal/output"
 	"github.com/rishijash/idk_terminal/internal/utils"
 )
 
 type PromptHandler struct {
-	config *configs.Config
+	config  *configs.Config
+	options Options
+	// pinnedActionType rejects backend answers of any other action type, set by templates
+	pinnedActionType string
 }
 
-func NewPromptHandler(config *configs.Config) PromptHandler {
+func NewPromptHandler(config *configs.Config, options Options) PromptHandler {
 	return PromptHandler{
-		config: config,
+		config:  config,
+		options: options,
 	}
 }
 
-func (h PromptHandler) HandlePrompt(prompt string, readme string) {
-	handlePromptImpl(prompt, readme, "", h)
+// promptResult is the JSON object printed for prompts in JSON output mode
+type promptResult struct {
+	ActionType string             `json:"actionType"`
+	Command    string             `json:"command,omitempty"`
+	Script     string             `json:"script,omitempty"`
+	Language   string             `json:"language,omitempty"`
+	Steps      []clients.PlanStep `json:"steps,omitempty"`
+	// Alternatives are other commands for the prompt, ranked from best to worst
+	Alternatives []clients.CommandAlternative `json:"alternatives,omitempty"`
+	Response     string                       `json:"response,omitempty"`
+	Explanation  string                       `json:"explanation,omitempty"`
+	Risk         string                       `json:"risk"`
+	RequestId    string                       `json:"requestId,omitempty"`
+	Quota        *clients.QuotaInfo           `json:"quota,omitempty"`
 }
 
-func handlePromptImpl(prompt string, readme string, existingScript string, h PromptHandler) {
+func (h PromptHandler) HandlePrompt(prompt string, readme string) error {
+	return handlePromptImpl(prompt, readme, "", nil, h)
+}
+
+// HandleTemplate runs a prompt template from the user config with `key=value` arguments.
+// Templates with a cached command run it without asking the backend.
+func (h PromptHandler) HandleTemplate(name string, template configs.PromptTemplate, templateArgs []string, readme string) error {
+	values, err := utils.ParseTemplateArgs(templateArgs)
+	if err != nil {
+		return NewError("invalid_input", err.Error())
+	}
+	placeholders := append(utils.TemplatePlaceholders(template.Prompt), utils.TemplatePlaceholders(template.Command)...)
+	for key := range values {
+		if !slices.Contains(placeholders, key) {
+			return NewError("invalid_input", fmt.Sprintf("Template @%s has no value `%s`", name, key))
+		}
+	}
+
+	prompt, err := utils.ExpandTemplate(template.Prompt, values, nil)
+	if err != nil {
+		return NewError("invalid_input", err.Error())
+	}
+
+	if template.Command != "" {
+		command, err := utils.ExpandTemplate(template.Command, values, utils.ShellQuote)
+		if err != nil {
+			return NewError("invalid_input", err.Error())
+		}
+		if prompt == "" {
+			prompt = "@" + name
+		}
+
+		risk := utils.AssessCommandRisk(command)
+		if h.options.IsJsonOutput() {
+			utils.PrintJson(promptResult{ActionType: "COMMAND", Command: command, Risk: risk})
+			return nil
+		}
+		// stderr keeps the note out of --print-only output
+		output.Infoln(fmt.Sprintf("Using the saved command of @%s", name))
+		return commandAction(prompt, command, risk, h)
+	}
+
+	h.pinnedActionType = strings.ToUpper(template.ActionType)
+	return handlePromptImpl(prompt, readme, "", nil, h)
+}
+
+func handlePromptImpl(prompt string, readme string, existingScript string, planFailure *clients.PlanFailure, h PromptHandler) error {
 	if prompt == "" {
-		println("Your prompt can not be empty")
-		println("Learn more :`idk -h`")
-		return
+		return NewError("invalid_input", "Your prompt can not be empty", "Learn more :`idk -h`")
 	}
 
-	token, err := utils.LoadToken()
+	token, err := loadToken()
 	if err != nil {
-		println("You are not logged in. Please login first")
-		println("Command: `idk --login`")
-		return
+		return err
 	}
 
 	readmeData := ""
 	if readme != "" {
 		readmeDataBytes, err := os.ReadFile(readme)
 		if err != nil {
-			fmt.Println("Error fetching README file. Please try again!")
-			return
+			return NewError("invalid_input", "Error fetching README file. Please try again!")
 		}
 		readmeData = string(readmeDataBytes)
 	}
 
-	customCharset := []string{"-", "\\", "|", "/", "-", ".", "o", "O", "0", "@"}
-	loadingSpinner := spinner.New(customCharset, 100*time.Millisecond)
-	loadingSpinner.Start()
+	loadingSpinner := h.options.startSpinner("")
+	promptResponse, err, responseStatus := processPrompt(h.config, prompt, readmeData, existingScript, planFailure, token)
+	loadingSpinner.Stop()
+	if err := responseError(responseStatus, err); err != nil {
+		return err
+	}
 
-	pwd, err := os.Getwd()
-	if err != nil {
-		pwd = ""
+	actionType := promptResponse.ActionType
+	if actionType == "COMMANDFROMREADME" {
+		actionType = "COMMAND"
+	}
+	if h.pinnedActionType != "" && actionType != h.pinnedActionType {
+		return NewError("unexpected_action_type", fmt.Sprintf("Expected a %s but got a %s. Please rephrase the template or try again!", strings.ToLower(h.pinnedActionType), strings.ToLower(actionType)))
+	}
+
+	if h.options.IsJsonOutput() {
+		printPromptResult(promptResponse)
+		return nil
 	}
 
-	promptResponse, err, responseStatus := clients.ProcessPrompt(prompt, runtime.GOOS, readmeData, existingScript, pwd, token, h.config.IdkBackendBaseUrl)
-	if responseStatus == http.StatusUnauthorized {
-		utils.ClearToken()
-		fmt.Println("Token expired. Please login again")
-		println("Command: `idk --login`")
-		return
+	risk := utils.MaxRisk(promptResponse.Risk, utils.AssessCommandRisk(promptResponse.Response))
+
+	switch promptResponse.ActionType {
+	case "COMMAND":
+		command, risk, ok := chooseCommand(promptResponse, risk, h)
+		if !ok {
+			output.Println("Command execution canceled")
+			return errCancelled
+		}
+		return commandAction(prompt, command, risk, h)
+	case "COMMANDFROMREADME":
+		return commandAction(prompt, promptResponse.Response, risk, h)
+	case "SCRIPT":
+		language := utils.DetectScriptLanguage(promptResponse.Language, promptResponse.Response)
+		return scriptAction(prompt, promptResponse.Response, language, risk, h)
+	case "PLAN":
+		return planAction(prompt, readme, promptResponse.Response, promptResponse.Steps, planFailure, h)
+	default:
+		output.Println(output.Markdown(promptResponse.Response))
+		return nil
 	}
+}
 
-	if responseStatus == http.StatusTooManyRequests {
-		fmt.Println("Daily Quota limit reached. Plesae try again tomorrow or upgrade on https://idk-cli.github.io/")
-		return
+// processPrompt sends a prompt to the backend together with the working directory and project context
+func processPrompt(config *configs.Config, prompt string, readmeData string, existingScript string, planFailure *clients.PlanFailure, token string) (*clients.PromptResponse, error, int) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		pwd = ""
 	}
 
+	// project context is best effort, prompts still work without it
+	projectContext, err := utils.GatherProjectContext()
 	if err != nil {
-		fmt.Println("Something went wrong. Please try again!")
-		return
+		projectContext = nil
 	}
 
-	loadingSpinner.Stop()
+	return clients.ProcessPrompt(prompt, runtime.GOOS, readmeData, existingScript, pwd, projectContext, planFailure, token, config.IdkBackendBaseUrl)
+}
+
+func printPromptResult(promptResponse *clients.PromptResponse) {
+	result := promptResult{
+		ActionType:  promptResponse.ActionType,
+		Explanation: promptResponse.Explanation,
+		RequestId:   promptResponse.RequestId,
+		Quota:       promptResponse.Quota,
+	}
 
 	switch promptResponse.ActionType {
-	case "COMMAND":
-		commandAction(promptResponse.Response)
-	case "COMMANDFROMREADME":
-		commandAction(promptResponse.Response)
+	case "COMMAND", "COMMANDFROMREADME":
+		result.Command = promptResponse.Response
+		result.Risk = utils.MaxRisk(promptResponse.Risk, utils.AssessCommandRisk(promptResponse.Response))
+		for _, alternative := range promptResponse.Alternatives {
+			alternative.Risk = utils.MaxRisk(alternative.Risk, utils.AssessCommandRisk(alternative.Command))
+			result.Alternatives = append(result.Alternatives, alternative)
+		}
 	case "SCRIPT":
-		scriptAction(promptResponse.Response, h)
+		result.Script = promptResponse.Response
+		result.Language = utils.DetectScriptLanguage(promptResponse.Language, promptResponse.Response).Name
+		result.Risk = utils.MaxRisk(promptResponse.Risk, utils.AssessCommandRisk(promptResponse.Response))
+	case "PLAN":
+		result.Steps = promptResponse.Steps
+		result.Response = promptResponse.Response
+		result.Risk = utils.RiskLow
+		for _, step := range promptResponse.Steps {
+			result.Risk = utils.MaxRisk(result.Risk, planStepRisk(step))
+		}
 	default:
-		println(promptResponse.Response)
+		result.Response = promptResponse.Response
+		result.Risk = utils.RiskLow
 	}
+
+	utils.PrintJson(result)
 }
 
 // ----------------------------------------------------------------------------------------
 // Script Logic
 // ----------------------------------------------------------------------------------------
-func scriptAction(script string, h PromptHandler) {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Println("Script:")
-	fmt.Println("----------------")
-	fmt.Println(script)
-	fmt.Println("----------------")
-	fmt.Printf("Do you want me to execute the script? (y/n/update/save): ")
-	response, _ := reader.ReadString('\n')
-	response = strings.TrimSpace(response) // Trim whitespace and newline character
-	var err error = nil
-
-	currentTime := time.Now()
-	timestampFromated := currentTime.Format("2006-01-02_15-04-05")
-	scriptFileName := fmt.Sprintf("idk_script_%s.sh", timestampFromated)
-
-	if strings.ToLower(response) == "y" {
-		err = runScript(script, scriptFileName)
-		fmt.Println("Script execution completed")
-	} else if strings.ToLower(response) == "update" {
-		fmt.Println("What do you want to change?")
-		reader = bufio.NewReader(os.Stdin)
-		updateResponse, _ := reader.ReadString('\n')
-		// readme is set to empty since scripts don't support readme
-		handlePromptImpl(updateResponse, "", script, h)
-	} else if strings.ToLower(response) == "save" {
-		err = saveScript(script, scriptFileName)
-		fmt.Printf("Script saved as %s", scriptFileName)
-	} else {
-		fmt.Println("Script execution canceled")
+func scriptAction(prompt string, script string, language utils.ScriptLanguage, risk string, h PromptHandler) error {
+	if h.options.PrintOnly {
+		output.Println(script)
+		return nil
+	}
+	if h.options.CopyOnly {
+		return copyToClipboard(script, "Script")
 	}
 
-	if err != nil {
-		fmt.Println("Something went wrong. Please try again!")
+	output.Printf("Script (%s):\n", language.Name)
+	output.Code(script, language.Name)
+	printRisk(risk)
+	response := h.options.confirm("Do you want me to execute the script?", []string{"y", "n", "update", "save"}, h.options.runRisk(risk))
+
+	switch response {
+	case "y":
+		err := runRecorded(prompt, "SCRIPT", script, language, func() error {
+			return runScript(script, language, risk, h)
+		})
+		if err != nil {
+			// timeouts, interrupts and non-zero exit codes of the script itself
+			return commandFailed("Script execution failed", err)
+		}
+		output.Println("Script execution completed")
+		return nil
+	case "update":
+		updateResponse := h.options.ask("What do you want to change?")
+		// readme is set to empty since scripts don't support readme
+		return handlePromptImpl(updateResponse, "", script, nil, h)
+	case "save":
+		if err := saveToLibrary(script, language, h.options); err != nil {
+			return NewError("internal_error", fmt.Sprintf("Something went wrong: %s", err))
+		}
+		return nil
+	default:
+		output.Println("Script execution canceled")
+		return errCancelled
 	}
 }
 
-func saveScript(script string, filePath string) error {
-	scriptBytes := []byte(script)
-	err := os.WriteFile(filePath, scriptBytes, 0600)
-	return err
+func runScript(script string, language utils.ScriptLanguage, risk string, h PromptHandler) error {
+	if h.options.StrictScripts {
+		script, language = utils.StrictScript(script, language)
+	}
+	return h.options.executor(risk).RunScript(script, language, utils.ScriptRunOptions{Timeout: h.options.ScriptTimeout})
 }
 
-func runScript(script string, fileName string) error {
-	// save file
-	scriptBytes := []byte(script)
-	err := os.WriteFile(fileName, scriptBytes, 0600)
-	if err != nil {
-		return err
+// ----------------------------------------------------------------------------------------
+// Plan Logic
+// ----------------------------------------------------------------------------------------
+
+// maxReplans limits how often a failing plan is sent back to the backend
+const maxReplans = 3
+
+func planAction(prompt string, readme string, summary string, steps []clients.PlanStep, planFailure *clients.PlanFailure, h PromptHandler) error {
+	if len(steps) == 0 {
+		return NewError("backend_error", "The plan has no steps. Please try again!")
+	}
+	if h.options.PrintOnly || h.options.CopyOnly {
+		var lines []string
+		for _, step := range steps {
+			lines = append(lines, step.Command)
+		}
+		if h.options.PrintOnly {
+			output.Println(strings.Join(lines, "\n"))
+			return nil
+		}
+		return copyToClipboard(strings.Join(lines, "\n"), "Commands")
+	}
+
+	// steps that already ran before a re-plan are kept, so the step numbers continue
+	var doneSteps []clients.PlanStep
+	if planFailure != nil {
+		doneSteps = planFailure.Steps[:planFailure.FailedStep]
+	}
+
+	if summary != "" {
+		output.Println(summary)
+	}
+	offset := len(doneSteps)
+	output.Printf("Plan with %d steps:\n", offset+len(steps))
+	for i, step := range steps {
+		output.Printf("  %d. %s\n", offset+i+1, step.Description)
+	}
+	output.Println("")
+
+	for i := 0; i < len(steps); i++ {
+		step := steps[i]
+		stepNumber := offset + i + 1
+		risk := planStepRisk(step)
+		output.Printf("[Step %d / %d] %s\n", stepNumber, offset+len(steps), step.Description)
+		output.Printf("Command: %s\n", step.Command)
+		if step.Precondition != "" {
+			output.Printf("Precondition: %s\n", step.Precondition)
+		}
+		if step.Verification != "" {
+			output.Printf("Verification: %s\n", step.Verification)
+		}
+		printRisk(risk)
+
+		response := h.options.confirm("Continue?", []string{"y", "skip", "stop"}, h.options.runRisk(risk))
+		if response == "skip" {
+			doneSteps = append(doneSteps, step)
+			continue
+		}
+		if response != "y" {
+			output.Println("Plan stopped")
+			return errCancelled
+		}
+
+		err := runPlanStep(prompt, step, risk, h)
+		if err == nil {
+			doneSteps = append(doneSteps, step)
+			continue
+		}
+
+		output.Printf("Step %d failed: %s\n", stepNumber, err)
+		replans := 0
+		if planFailure != nil {
+			replans = planFailure.Replans
+		}
+		choices := []string{"replan", "retry", "skip", "stop"}
+		if replans >= maxReplans {
+			// stop comes first so --yes does not retry forever
+			choices = []string{"stop", "retry", "skip"}
+		}
+		switch h.options.confirm("What do you want to do?", choices, utils.RiskLow) {
+		case "replan":
+			failure := &clients.PlanFailure{
+				Steps:      append(append([]clients.PlanStep{}, doneSteps...), steps[i:]...),
+				FailedStep: len(doneSteps),
+				Error:      err.Error(),
+				Replans:    replans + 1,
+			}
+			return handlePromptImpl(prompt, readme, "", failure, h)
+		case "retry":
+			i--
+		case "skip":
+			doneSteps = append(doneSteps, step)
+		default:
+			output.Println("Plan stopped")
+			return commandFailed(fmt.Sprintf("Step %d failed", stepNumber), err)
+		}
 	}
+	output.Println("Plan completed")
+	return nil
+}
 
-	utils.RunCommand(fmt.Sprintf(". %s", fileName))
+// runPlanStep checks the precondition of a step, runs it and verifies the result.
+// The checks run where the step runs, in the sandbox with --sandbox.
+func runPlanStep(prompt string, step clients.PlanStep, risk string, h PromptHandler) error {
+	if step.Precondition != "" {
+		if err := h.options.executor(risk).RunCommand(step.Precondition); err != nil {
+			return fmt.Errorf("precondition `%s` failed: %w", step.Precondition, err)
+		}
+	}
 
-	err = os.Remove(fileName)
+	err := runRecorded(prompt, "PLAN", step.Command, utils.ShellLanguage, func() error {
+		return h.options.executor(risk).RunCommand(step.Command)
+	})
 	if err != nil {
 		return err
 	}
 
+	if step.Verification != "" {
+		if err := h.options.executor(risk).RunCommand(step.Verification); err != nil {
+			return fmt.Errorf("verification `%s` failed: %w", step.Verification, err)
+		}
+		output.Println("Verified")
+	}
 	return nil
 }
 
+// planStepRisk is the risk of everything a step runs, including its checks
+func planStepRisk(step clients.PlanStep) string {
+	risk := utils.MaxRisk(utils.AssessCommandRisk(step.Command), utils.AssessCommandRisk(step.Precondition))
+	return utils.MaxRisk(risk, utils.AssessCommandRisk(step.Verification))
+}
+
 // ----------------------------------------------------------------------------------------
 // Command Logic
 // ----------------------------------------------------------------------------------------
 
-func commandAction(command string) {
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Printf("Do you want me to execute `%s`? (y/n/copy): ", command)
-	response, _ := reader.ReadString('\n')
-	response = strings.TrimSpace(response) // Trim whitespace and newline character
-	var err error = nil
-
-	if strings.ToLower(response) == "y" {
-		err = utils.RunCommand(command)
-	} else if strings.ToLower(response) == "copy" {
-		err := clipboard.WriteAll(command)
+// chooseCommand lets the user pick between the command and its alternatives. Without
+// alternatives, or when the command is only printed or copied, the top ranked command is used.
+func chooseCommand(promptResponse *clients.PromptResponse, risk string, h PromptHandler) (string, string, bool) {
+	if len(promptResponse.Alternatives) == 0 || h.options.PrintOnly || h.options.CopyOnly {
+		return promptResponse.Response, risk, true
+	}
+
+	commands := []string{promptResponse.Response}
+	risks := []string{risk}
+	items := []string{choiceLabel(promptResponse.Response, "recommended", risk)}
+	for _, alternative := range promptResponse.Alternatives {
+		alternativeRisk := utils.MaxRisk(alternative.Risk, utils.AssessCommandRisk(alternative.Command))
+		commands = append(commands, alternative.Command)
+		risks = append(risks, alternativeRisk)
+		items = append(items, choiceLabel(alternative.Command, alternative.Note, alternativeRisk))
+	}
+
+	selected := h.options.choose("Pick a command", items)
+	if selected < 0 {
+		return "", "", false
+	}
+	return commands[selected], risks[selected], true
+}
+
+func choiceLabel(command string, note string, risk string) string {
+	label := command
+	if note != "" {
+		label += "  - " + note
+	}
+	if risk != utils.RiskLow && utils.IsValidRisk(risk) {
+		label += fmt.Sprintf(" (%s risk)", risk)
+	}
+	return label
+}
+
+func commandAction(prompt string, command string, risk string, h PromptHandler) error {
+	if h.options.PrintOnly {
+		output.Println(command)
+		return nil
+	}
+	if h.options.CopyOnly {
+		return copyToClipboard(command, "Command")
+	}
+
+	printRisk(risk)
+	response := h.options.confirm(fmt.Sprintf("Do you want me to execute `%s`?", command), []string{"y", "n", "copy"}, h.options.runRisk(risk))
+
+	switch response {
+	case "y":
+		err := runRecorded(prompt, "COMMAND", command, utils.ShellLanguage, func() error {
+			return h.options.executor(risk).RunCommand(command)
+		})
 		if err != nil {
-			fmt.Println("Failed to copy command to clipboard")
-			return
+			return commandFailed("Command failed", err)
 		}
-		fmt.Println("Command copied to clipboard")
-	} else {
-		fmt.Println("Command execution canceled")
+		return nil
+	case "copy":
+		return copyToClipboard(command, "Command")
+	default:
+		output.Println("Command execution canceled")
+		return errCancelled
 	}
+}
 
+// copyToClipboard copies text to the clipboard, what names the copied thing in messages
+func copyToClipboard(text string, what string) error {
+	err := clipboard.WriteAll(text)
 	if err != nil {
-		fmt.Println("Something went wrong. Please try again!")
+		return NewError("internal_error", fmt.Sprintf("Failed to copy %s to clipboard", strings.ToLower(what)))
 	}
+	output.Printf("%s copied to clipboard\n", what)
+	return nil
 }
@@ -1,155 +1,718 @@
 package handler
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 
-	"github.com/briandowns/spinner"
 	"github.com/rishijash/idk_terminal/configs"
 	"github.com/rishijash/idk_terminal/internal/clients"
+	"github.com/rishijash/idk_terminal/internal/output"
 	"github.com/rishijash/idk_terminal/internal/utils"
 )
 
+const (
+	// setupBuildTimeout stops builds and tests that hang while verifying a setup
+	setupBuildTimeout = 10 * time.Minute
+	// setupRunTimeout is how long the run command may take to get ready
+	setupRunTimeout = time.Minute
+)
+
 type RunHandler struct {
-	config *configs.Config
+	config  *configs.Config
+	options Options
 }
 
-func NewRunHandler(config *configs.Config) RunHandler {
+func NewRunHandler(config *configs.Config, options Options) RunHandler {
 	return RunHandler{
-		config: config,
+		config:  config,
+		options: options,
 	}
 }
 
-func (h RunHandler) HandleSetupProject(ctx context.Context) {
-	token, err := utils.LoadToken()
+// setupResult is the JSON object printed for project setup in JSON output mode
+type setupResult struct {
+	ProjectType string                             `json:"projectType"`
+	Commands    []clients.RunGetProjectInitCommand `json:"commands"`
+	RunCommand  string                             `json:"runCommand"`
+	// PackageManager is the manager the commands were generated for, empty if none was found
+	PackageManager string             `json:"packageManager,omitempty"`
+	RequestId      string             `json:"requestId,omitempty"`
+	Quota          *clients.QuotaInfo `json:"quota,omitempty"`
+}
+
+// HandleSetupProject asks the backend how to setup the project in the working directory and runs the steps.
+// With resume it continues the last setup of the project from the step it stopped at instead. With an export
+// format the steps of the last setup, or new ones if there was none, are written to the project instead of run.
+// With verifyOnly the last setup is only verified.
+func (h RunHandler) HandleSetupProject(ctx context.Context, resume bool, export string, verifyOnly bool) error {
+	token, err := loadToken()
+	if err != nil {
+		return err
+	}
+
+	pwd, err := os.Getwd()
 	if err != nil {
-		println("You are not logged in. Please login first")
-		println("Command: `idk --login`")
-		return
+		return NewError("internal_error", "Something went wrong. Please try again!")
+	}
+	state, err := utils.LoadSetupState(pwd)
+	if err != nil {
+		output.Warnln("Ignoring the invalid setup state of this project: " + err.Error())
+		state = nil
+	}
+
+	if export != "" && state != nil {
+		return h.exportSetup(pwd, state, export)
+	}
+	if verifyOnly {
+		if state == nil {
+			return NewError("invalid_input", "There is no setup to verify in this folder", "Start one with `idk setup`")
+		}
+		return h.verifySetup(pwd, state, token)
+	}
+
+	interactive := !h.options.IsJsonOutput() && !h.options.PrintOnly && !h.options.CopyOnly
+	if !resume && interactive && state != nil && !state.IsComplete() {
+		question := fmt.Sprintf("The last setup of this project stopped at step %d / %d. Resume it?", state.NextStep()+1, len(state.Steps))
+		resume = h.options.confirm(question, []string{"y", "n"}, utils.RiskLow) == "y"
+	}
+	if resume {
+		if state == nil {
+			return NewError("invalid_input", "There is no setup to resume in this folder", "Start one with `idk setup`")
+		}
+		return h.runSetupSteps(pwd, state, token)
 	}
 
 	files, err := utils.ListFilesAndDirs()
 
 	if err != nil {
-		println("Something went wrong. Please try again!")
-		return
+		return NewError("internal_error", "Something went wrong. Please try again!")
 	}
 
 	readmeData, err := utils.FindReadmeData()
 	if err != nil {
-		println("Something went wrong. Please try again!")
-		return
+		return NewError("internal_error", "Something went wrong. Please try again!")
 	}
 
 	makefileData, err := utils.FindMakefileData()
 	if err != nil {
-		println("Something went wrong. Please try again!")
-		return
+		return NewError("internal_error", "Something went wrong. Please try again!")
 	}
 
 	projectFolderName, err := utils.GetCurrentDirName()
 	if err != nil {
-		println("Something went wrong. Please try again!")
-		return
+		return NewError("internal_error", "Something went wrong. Please try again!")
+	}
+
+	projectScan, err := utils.ScanProject(pwd)
+	if err != nil {
+		return NewError("internal_error", "Something went wrong. Please try again!")
 	}
 
-	fmt.Println("Analyzing Project..")
-	customCharset := []string{"-", "\\", "|", "/", "-", ".", "o", "O", "0", "@"}
-	loadingSpinner := spinner.New(customCharset, 100*time.Millisecond)
-	loadingSpinner.Start()
+	// commands use the managers the machine already has instead of installing new ones
+	packageManagers := utils.DetectPackageManagers()
+	packageManager := utils.DefaultPackageManager(packageManagers)
+
+	loadingSpinner := h.options.startSpinner("Analyzing Project..")
 
 	response, err, responseStatus := clients.ProcessGetProjectInit(
-		projectFolderName, files, readmeData, makefileData, runtime.GOOS, token, h.config.IdkBackendBaseUrl)
+		projectFolderName, files, readmeData, makefileData, projectScan, runtime.GOOS,
+		packageManager, utils.PackageManagerNames(packageManagers), token, h.config.IdkBackendBaseUrl)
 
 	loadingSpinner.Stop()
 
-	if !utils.IsBrewInstalled() {
-		println("Brew is not installed. Installing brew first")
-		err := utils.InstallBrew()
-		if err != nil {
-			println("failed to install brew. Please manually install brew before continuing")
-			return
-		}
+	if h.options.IsJsonOutput() {
+		return h.printSetupResult(response, packageManager, responseStatus, err)
 	}
 
-	if h.isErrorResonse(responseStatus, err) {
-		return
+	if err := responseError(responseStatus, err); err != nil {
+		return err
 	}
 
 	if len(response.Commands) == 0 {
-		println("Something went wrong. Please try again!")
-		return
+		return NewError("backend_error", "Something went wrong. Please try again!")
 	}
 
-	h.executeCommandsAction(response.ProjectType, response.Commands)
+	if export != "" {
+		return h.exportSetup(pwd, newSetupState(response), export)
+	}
+	return h.executeCommandsAction(pwd, response, token)
+}
+
+// HandleSetupFromGit clones the repository at gitUrl into dir, checks out ref if it is set and sets up the clone.
+// Without dir the clone goes where `git clone` would put it, after asking.
+func (h RunHandler) HandleSetupFromGit(ctx context.Context, gitUrl string, dir string, ref string, export string) error {
+	if _, err := loadToken(); err != nil {
+		return err
+	}
+
+	interactive := !h.options.IsJsonOutput() && !h.options.PrintOnly && !h.options.CopyOnly && !h.options.AutoConfirm
+	if dir == "" {
+		dir = utils.CloneDirName(gitUrl)
+		if interactive {
+			if answer := h.options.ask(fmt.Sprintf("Clone into (default: %s):", dir)); answer != "" {
+				dir = answer
+			}
+		}
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return NewError("invalid_input", fmt.Sprintf("Invalid directory %s", dir))
+	}
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return NewError("invalid_input", fmt.Sprintf("%s already exists and is not empty", dir), "Choose another directory with --dir")
+	}
+
+	if err := utils.CloneRepository(gitUrl, dir, ref); err != nil {
+		return commandFailed("Failed to clone "+gitUrl, err)
+	}
+	if utils.HasSubmodules(dir) {
+		output.Infoln("Checking out submodules")
+		if err := utils.UpdateSubmodules(dir, utils.IsLocalGitUrl(gitUrl)); err != nil {
+			return commandFailed("Failed to check out the submodules of "+gitUrl, err)
+		}
+	}
+	if utils.UsesGitLfs(dir) {
+		if utils.IsGitLfsInstalled() {
+			if err := utils.PullGitLfs(dir); err != nil {
+				output.Warnln(fmt.Sprintf("Could not download the Git LFS files, run `git lfs pull` in %s: %s", dir, err))
+			}
+		} else {
+			output.Warnln("This repository stores files in Git LFS, which is not installed. Install it and run `git lfs pull` to get them")
+		}
+	}
 
+	output.Infoln(fmt.Sprintf("Cloned %s into %s", gitUrl, dir))
+	if err := os.Chdir(dir); err != nil {
+		return NewError("internal_error", "Something went wrong. Please try again!")
+	}
+	if err := h.HandleSetupProject(ctx, false, export, false); err != nil {
+		return err
+	}
+	if interactive {
+		output.Printf("Your project is in %s\n", dir)
+	}
+	return nil
 }
 
-func (h RunHandler) executeCommandsAction(projectType string, commands []clients.RunGetProjectInitCommand) {
-	utils.PrintMessages([]string{
-		fmt.Sprintf("`%s` found", projectType),
+func (h RunHandler) executeCommandsAction(pwd string, response *clients.RunGetProjectInitResponse, token string) error {
+	commands := response.Commands
+	if h.options.PrintOnly || h.options.CopyOnly {
+		var lines []string
+		for _, command := range commands {
+			lines = append(lines, command.Command)
+		}
+		if h.options.PrintOnly {
+			output.Println(strings.Join(lines, "\n"))
+			return nil
+		}
+		return copyToClipboard(strings.Join(lines, "\n"), "Commands")
+	}
+
+	return h.runSetupSteps(pwd, newSetupState(response), token)
+}
+
+// newSetupState turns the commands of the backend into setup steps. The last command runs the
+// project, every command before it is a setup step.
+func newSetupState(response *clients.RunGetProjectInitResponse) *utils.SetupState {
+	commands := response.Commands
+	state := &utils.SetupState{
+		ProjectType:  response.ProjectType,
+		RunCommand:   commands[len(commands)-1].Command,
+		BuildCommand: response.BuildCommand,
+		TestCommand:  response.TestCommand,
+		ReadyPort:    response.ReadyPort,
+		ReadyLog:     response.ReadyLog,
+	}
+	for _, command := range commands[:len(commands)-1] {
+		state.Steps = append(state.Steps, utils.SetupStep{
+			Id:          command.Id,
+			Command:     command.Command,
+			Description: command.Description,
+			DependsOn:   command.DependsOn,
+			Parallel:    command.Parallel,
+			Check:       command.Check,
+			Status:      utils.SetupStepPending,
+		})
+	}
+	return state
+}
+
+// runSetupSteps runs the steps of state that are not done yet, saving the progress after every step
+func (h RunHandler) runSetupSteps(pwd string, state *utils.SetupState, token string) error {
+	if state.HasDependencies() {
+		err := utils.ValidateSetupPlan(state)
+		if err == nil {
+			return h.runSetupPlan(pwd, state, token)
+		}
+		output.Warnln("Running the steps one after the other, their dependencies are invalid: " + err.Error())
+	}
+
+	output.Box(
+		fmt.Sprintf("`%s` found", state.ProjectType),
 		"Commands will be executed in sequence to get your project setup:",
-	})
+	)
+	h.saveSetupState(pwd, state)
 
-	for i, command := range commands {
-		if i == len(commands)-1 {
-			// last command is to run the project skip it
+	for i := range state.Steps {
+		step := &state.Steps[i]
+		stepLabel := fmt.Sprintf("[Step %d / %d]", i+1, len(state.Steps))
+		if step.Status != utils.SetupStepPending && step.Status != utils.SetupStepFailed {
+			output.Printf("%s %s: %s\n", stepLabel, step.Description, step.Status)
 			continue
 		}
 
-		reader := bufio.NewReader(os.Stdin)
-		println(fmt.Sprintf("[Step %d / %d]", i+1, len(commands)-1))
-		println(fmt.Sprintf("Command: %s", command.Command))
-		println(fmt.Sprintf("Description: %s", command.Description))
-		println("")
-		println("Continue? (y/skip/stop)")
-		response, _ := reader.ReadString('\n')
-		response = strings.TrimSpace(response) // Trim whitespace and newline character
-		if response == "y" {
-			err := utils.RunCommand(command.Command)
-			if err != nil {
-				println("Error setting up project. Please try again!")
-				return
-			}
-		} else if response == "skip" {
+		risk := stepRisk(*step)
+		output.Println(stepLabel)
+		output.Printf("Command: %s\n", output.Highlight(step.Command, "shell"))
+		if step.Check != "" {
+			output.Printf("Check: %s\n", output.Highlight(step.Check, "shell"))
+		}
+		output.Printf("Description: %s\n", step.Description)
+		output.Println()
+		printRisk(risk)
+		response := h.options.confirm("Continue?", []string{"y", "skip", "stop"}, risk)
+		if response == "skip" {
+			h.setStepStatus(pwd, state, step, utils.SetupStepSkipped, "", nil)
 			continue
-		} else {
-			println("Project Setup Cancelled")
-			return
+		}
+		if response != "y" {
+			output.Println("Project Setup Cancelled. Continue it with `idk setup --resume`")
+			return errCancelled
+		}
+		// the check comes from the backend or a saved state like the command, it only runs once confirmed
+		if step.Check != "" && utils.CommandSucceeds(step.Check) {
+			output.Printf("%s %s: already satisfied\n", stepLabel, step.Description)
+			h.setStepStatus(pwd, state, step, utils.SetupStepSatisfied, "", nil)
+			continue
+		}
+		skip, err := h.ensurePackageManager(step.Command)
+		if err != nil {
+			return err
+		}
+		if skip {
+			h.setStepStatus(pwd, state, step, utils.SetupStepSkipped, "", nil)
+			continue
+		}
+
+		if err := h.runSetupStep(pwd, state, step, stepLabel, token); err != nil {
+			return err
 		}
 	}
-	utils.PrintMessages([]string{
+	return h.finishSetup(pwd, state, token)
+}
+
+// finishSetup prints the run command of a completed setup and offers to verify and export it
+func (h RunHandler) finishSetup(pwd string, state *utils.SetupState, token string) error {
+	output.Box(
 		"Project Setup Completed",
 		"",
 		"Run your Project with following command:",
-		commands[len(commands)-1].Command,
-	})
+		output.Highlight(state.RunCommand, "shell"),
+	)
+
+	var verifyErr error
+	if h.options.confirm("Verify the setup by building, testing and starting the project?", []string{"y", "n"}, utils.RiskLow) == "y" {
+		verifyErr = h.verifySetup(pwd, state, token)
+	}
+
+	// keep what this setup learned for the next person, n comes first so it is the default
+	choices := append([]string{"n"}, utils.SetupExportFormats...)
+	format := h.options.confirm("Export the setup steps for others?", choices, utils.RiskLow)
+	if format != "" && format != "n" {
+		if err := h.exportSetup(pwd, state, format); err != nil {
+			return err
+		}
+	}
+	return verifyErr
+}
+
+// verifySetup builds, tests and starts the project, offers to debug failures and writes a setup report.
+// Failed checks return an error that was already reported.
+func (h RunHandler) verifySetup(pwd string, state *utils.SetupState, token string) error {
+	buildCommand, testCommand := state.BuildCommand, state.TestCommand
+	if buildCommand == "" && testCommand == "" {
+		if projectScan, err := utils.ScanProject(pwd); err == nil {
+			buildCommand, testCommand = utils.DefaultVerifyCommands(projectScan)
+		}
+	}
+
+	checks := []struct {
+		name    string
+		command string
+		options utils.VerifyOptions
+	}{
+		{"Build", buildCommand, utils.VerifyOptions{Timeout: setupBuildTimeout}},
+		{"Test", testCommand, utils.VerifyOptions{Timeout: setupBuildTimeout}},
+		{"Run", state.RunCommand, utils.VerifyOptions{Timeout: setupRunTimeout, LongRunning: true, ReadyPort: state.ReadyPort, ReadyLog: state.ReadyLog}},
+	}
+
+	var verification []utils.SetupVerification
+	failed := false
+	for _, check := range checks {
+		result := utils.SetupVerification{Name: check.name, Command: check.command, Status: utils.VerifySkipped}
+		if check.command == "" || failed {
+			// nothing runs once a check failed, a failed build fails the tests too
+			verification = append(verification, result)
+			continue
+		}
+
+		output.Box(fmt.Sprintf("Verifying: %s", check.name), output.Highlight(check.command, "shell"))
+		start := time.Now()
+		commandOutput, err := utils.RunVerifyCommand(check.command, check.options)
+		result.Duration = time.Since(start)
+		if err == nil {
+			result.Status = utils.VerifyPassed
+			verification = append(verification, result)
+			continue
+		}
+
+		failed = true
+		result.Status = utils.VerifyFailed
+		result.Error = err.Error()
+		verification = append(verification, result)
+		output.Errorln(fmt.Sprintf("%s failed: %s", check.name, err))
+
+		if h.options.confirm("Debug the failure?", []string{"y", "n"}, utils.RiskLow) == "y" {
+			// the output tells the backend more than the exit status alone
+			debugErr := NewDebugHandler(h.config, h.options).commandDebugAction(check.command, fmt.Errorf("%w\n%s", err, commandOutput), token)
+			var handlerErr *Error
+			if errors.As(debugErr, &handlerErr) && handlerErr.Code != "command_failed" {
+				h.options.ReportError(debugErr)
+			}
+		}
+	}
+
+	report := utils.NewSetupReport(state, verification)
+	output.Println(output.Markdown(report.Markdown()))
+	if path, err := utils.SaveSetupReport(pwd, report); err == nil {
+		output.Println("Setup report saved to " + path)
+	} else {
+		output.Warnln("Could not save the setup report: " + err.Error())
+	}
+
+	if failed {
+		return NewError("verification_failed")
+	}
+	return nil
 }
 
-func (h RunHandler) isErrorResonse(responseStatus int, err error) bool {
-	if responseStatus == http.StatusUnauthorized {
-		utils.ClearToken()
-		fmt.Println("Token expired. Please login again")
-		println("Command: `idk --login`")
-		return true
+// exportSetup writes the steps of state to the project as a script, Makefile target, devcontainer or CONTRIBUTING section
+func (h RunHandler) exportSetup(pwd string, state *utils.SetupState, format string) error {
+	files, err := utils.ExportSetup(pwd, state, format)
+	if err != nil {
+		return NewError("export_failed", "Could not export the setup: "+err.Error())
 	}
+	for _, file := range files {
+		if relPath, err := filepath.Rel(pwd, file); err == nil {
+			file = relPath
+		}
+		output.Println("Wrote " + file)
+	}
+	return nil
+}
+
+// runSetupStep runs a confirmed step until it succeeds or the user skips it or stops the setup
+func (h RunHandler) runSetupStep(pwd string, state *utils.SetupState, step *utils.SetupStep, stepLabel string, token string) error {
+	for {
+		outputHash, err := utils.RunCommandOnTerminal(step.Command)
+		if err == nil {
+			h.setStepStatus(pwd, state, step, utils.SetupStepDone, outputHash, nil)
+			return nil
+		}
+		h.setStepStatus(pwd, state, step, utils.SetupStepFailed, outputHash, err)
+		output.Printf("%s failed: %s\n", stepLabel, err)
 
-	if responseStatus == http.StatusTooManyRequests {
-		fmt.Println("Daily Quota limit reached. Plesae try again tomorrow or upgrade on https://idk-cli.github.io/")
-		return true
+		retry, resolveErr := h.resolveFailedStep(pwd, state, step, outputHash, err, token)
+		if !retry {
+			return resolveErr
+		}
 	}
+}
 
+// resolveFailedStep asks what to do about a failed step until it is retried, skipped or the setup is stopped
+func (h RunHandler) resolveFailedStep(pwd string, state *utils.SetupState, step *utils.SetupStep, outputHash string, err error, token string) (bool, error) {
+	choices := []string{"retry", "debug", "skip", "stop"}
+	if h.options.AutoConfirm {
+		// stop comes first so --yes does not retry forever
+		choices = []string{"stop", "retry", "debug", "skip"}
+	}
+	for {
+		response := h.options.confirm("What do you want to do?", choices, utils.RiskLow)
+		if response == "debug" {
+			// the explanation is printed by the debug handler, only failures to get one stop the setup
+			debugErr := NewDebugHandler(h.config, h.options).commandDebugAction(step.Command, err, token)
+			var handlerErr *Error
+			if errors.As(debugErr, &handlerErr) && handlerErr.Code != "command_failed" {
+				return false, debugErr
+			}
+			continue
+		}
+		if response == "retry" {
+			return true, nil
+		}
+		if response == "skip" {
+			h.setStepStatus(pwd, state, step, utils.SetupStepSkipped, outputHash, nil)
+			return false, nil
+		}
+		output.Println("Project Setup Stopped. Continue it with `idk setup --resume`")
+		return false, commandFailed("Error setting up project", err)
+	}
+}
+
+// stepRisk is the risk of a step, its check runs on the host just like its command
+func stepRisk(step utils.SetupStep) string {
+	return utils.MaxRisk(utils.AssessCommandRisk(step.Command), utils.AssessCommandRisk(step.Check))
+}
+
+// setupStepResult is the outcome of a step of a setup plan
+type setupStepResult struct {
+	index      int
+	outputHash string
+	err        error
+}
+
+// runSetupPlan runs steps that declare dependencies. The whole plan is approved once, then every step runs as
+// soon as the steps it depends on finished, parallel ones next to each other.
+func (h RunHandler) runSetupPlan(pwd string, state *utils.SetupState, token string) error {
+	output.Box(
+		fmt.Sprintf("`%s` found", state.ProjectType),
+		"Commands will be executed as soon as the steps they depend on finished:",
+	)
+	h.saveSetupState(pwd, state)
+
+	risk := utils.RiskLow
+	for i, step := range state.Steps {
+		line := fmt.Sprintf("[%s] %s", state.StepId(i), step.Description)
+		if len(step.DependsOn) > 0 {
+			line += fmt.Sprintf(" (after %s)", strings.Join(step.DependsOn, ", "))
+		}
+		if step.Parallel {
+			line += " (parallel)"
+		}
+		if utils.IsStepFinished(step) {
+			output.Printf("%s: %s\n", line, step.Status)
+			continue
+		}
+		output.Println(line)
+		output.Printf("    %s\n", output.Highlight(step.Command, "shell"))
+		if step.Check != "" {
+			output.Printf("    check: %s\n", output.Highlight(step.Check, "shell"))
+		}
+		risk = utils.MaxRisk(risk, stepRisk(step))
+	}
+	output.Println()
+	printRisk(risk)
+	if h.options.confirm("Run the plan?", []string{"y", "n"}, risk) != "y" {
+		output.Println("Project Setup Cancelled. Continue it with `idk setup --resume`")
+		return errCancelled
+	}
+	for i := range state.Steps {
+		step := &state.Steps[i]
+		if utils.IsStepFinished(*step) {
+			continue
+		}
+		skip, err := h.ensurePackageManager(step.Command)
+		if err != nil {
+			return err
+		}
+		if skip {
+			h.setStepStatus(pwd, state, step, utils.SetupStepSkipped, "", nil)
+		}
+	}
+
+	for {
+		failed := h.runReadySteps(pwd, state)
+		if len(failed) == 0 {
+			break
+		}
+		for _, result := range failed {
+			step := &state.Steps[result.index]
+			output.Printf("[%s] %s failed: %s\n", state.StepId(result.index), step.Description, result.err)
+			if _, err := h.resolveFailedStep(pwd, state, step, result.outputHash, result.err, token); err != nil {
+				return err
+			}
+		}
+	}
+	return h.finishSetup(pwd, state, token)
+}
+
+// runReadySteps runs the steps of a plan whose dependencies finished until every step finished or one failed.
+// After a failure no new step starts, the running ones are waited for. It returns the failed steps.
+func (h RunHandler) runReadySteps(pwd string, state *utils.SetupState) []setupStepResult {
+	results := make(chan setupStepResult)
+	started := map[int]time.Time{}
+	running := 0
+	var failed []setupStepResult
+
+	// finish records the result of a step, only this goroutine changes the state
+	finish := func(result setupStepResult) {
+		step := &state.Steps[result.index]
+		id := state.StepId(result.index)
+		if result.err != nil {
+			h.setStepStatus(pwd, state, step, utils.SetupStepFailed, result.outputHash, result.err)
+			output.Printf("[%s] Failed after %s\n", id, time.Since(started[result.index]).Round(time.Millisecond))
+			failed = append(failed, result)
+			return
+		}
+		h.setStepStatus(pwd, state, step, utils.SetupStepDone, result.outputHash, nil)
+		output.Printf("[%s] Done in %s (%d / %d)\n", id, time.Since(started[result.index]).Round(time.Millisecond), finishedSteps(state), len(state.Steps))
+	}
+
+	// start begins the ready steps in order and reports if it changed anything. A step that
+	// isn't parallel waits until nothing else runs and holds back the steps after it.
+	start := func() bool {
+		changed := false
+		for i := range state.Steps {
+			step := &state.Steps[i]
+			if _, ok := started[i]; ok || utils.IsStepFinished(*step) || !dependenciesFinished(state, i) {
+				continue
+			}
+			if !step.Parallel && running > 0 {
+				return changed
+			}
+			id := state.StepId(i)
+			started[i] = time.Now()
+			changed = true
+			if step.Check != "" && utils.CommandSucceeds(step.Check) {
+				output.Printf("[%s] %s: already satisfied\n", id, step.Description)
+				h.setStepStatus(pwd, state, step, utils.SetupStepSatisfied, "", nil)
+				continue
+			}
+
+			output.Printf("[%s] Started: %s\n", id, step.Description)
+			if !step.Parallel {
+				// steps that run alone keep the terminal so they can ask for input
+				outputHash, err := utils.RunCommandOnTerminal(step.Command)
+				finish(setupStepResult{index: i, outputHash: outputHash, err: err})
+				return true
+			}
+			running++
+			go func(index int, command string, prefix string) {
+				outputHash, err := utils.RunCommandWithPrefix(command, prefix)
+				results <- setupStepResult{index: index, outputHash: outputHash, err: err}
+			}(i, step.Command, fmt.Sprintf("[%s] ", id))
+		}
+		return changed
+	}
+
+	for {
+		if len(failed) == 0 && start() {
+			continue
+		}
+		if running == 0 {
+			break
+		}
+		finish(<-results)
+		running--
+	}
+
+	if len(failed) > 0 {
+		var blocked []string
+		for i, step := range state.Steps {
+			if _, ok := started[i]; !ok && !utils.IsStepFinished(step) {
+				blocked = append(blocked, state.StepId(i))
+			}
+		}
+		if len(blocked) > 0 {
+			output.Printf("Waiting for the failed steps: %s\n", strings.Join(blocked, ", "))
+		}
+	}
+	return failed
+}
+
+// dependenciesFinished checks if every step step i depends on is done, satisfied or skipped
+func dependenciesFinished(state *utils.SetupState, i int) bool {
+	for _, dependency := range state.StepDependencies(i) {
+		if !utils.IsStepFinished(state.Steps[dependency]) {
+			return false
+		}
+	}
+	return true
+}
+
+func finishedSteps(state *utils.SetupState) int {
+	count := 0
+	for _, step := range state.Steps {
+		if utils.IsStepFinished(step) {
+			count++
+		}
+	}
+	return count
+}
+
+func (h RunHandler) setStepStatus(pwd string, state *utils.SetupState, step *utils.SetupStep, status string, outputHash string, err error) {
+	step.Status = status
+	if outputHash != "" {
+		step.OutputHash = outputHash
+	}
+	step.Error = ""
 	if err != nil {
-		fmt.Println("Something went wrong. Please try again!")
-		return true
+		step.Error = err.Error()
+	}
+	h.saveSetupState(pwd, state)
+}
+
+// saveSetupState saves the setup progress, a setup that can't be resumed is still worth finishing
+func (h RunHandler) saveSetupState(pwd string, state *utils.SetupState) {
+	if err := utils.SaveSetupState(pwd, state); err != nil {
+		output.Warnln("Could not save the setup progress: " + err.Error())
+	}
+}
+
+// ensurePackageManager offers to install the package manager a setup command needs if it is missing.
+// It returns true if the step should be skipped because the package manager can't be installed by idk.
+// Declining the install returns errCancelled, the command would fail without it.
+func (h RunHandler) ensurePackageManager(command string) (bool, error) {
+	manager := utils.RequiredPackageManager(command)
+	if manager == nil || utils.IsPackageManagerInstalled(*manager) {
+		return false, nil
+	}
+	if manager.Install == "" {
+		output.Printf("This step needs %s, which is not installed and idk can't install it for you\n", manager.Name)
+		if h.options.confirm("Skip the step or stop to install it yourself?", []string{"skip", "stop"}, utils.RiskLow) == "skip" {
+			return true, nil
+		}
+		output.Printf("Project Setup Stopped. Install %s, then continue it with `idk setup --resume`\n", manager.Name)
+		return false, errCancelled
+	}
+
+	output.Printf("This step needs %s, which is not installed\n", manager.Name)
+	output.Printf("Command: %s\n", output.Highlight(manager.Install, "shell"))
+	risk := utils.AssessCommandRisk(manager.Install)
+	printRisk(risk)
+	if h.options.confirm(fmt.Sprintf("Install %s?", manager.Name), []string{"y", "n"}, risk) != "y" {
+		output.Println("Project Setup Cancelled")
+		return false, errCancelled
+	}
+	if err := utils.InstallPackageManager(*manager); err != nil {
+		return false, commandFailed(fmt.Sprintf("Failed to install %s. Please install it manually before continuing", manager.Name), err)
+	}
+	return false, nil
+}
+
+func (h RunHandler) printSetupResult(response *clients.RunGetProjectInitResponse, packageManager string, responseStatus int, err error) error {
+	if err := responseError(responseStatus, err); err != nil {
+		return err
 	}
 
-	return false
+	if len(response.Commands) == 0 {
+		return NewError("backend_error", "Something went wrong. Please try again!")
+	}
 
+	// the last command runs the project, every command before it is a setup step
+	utils.PrintJson(setupResult{
+		ProjectType:    response.ProjectType,
+		Commands:       response.Commands[:len(response.Commands)-1],
+		RunCommand:     response.Commands[len(response.Commands)-1].Command,
+		PackageManager: packageManager,
+		RequestId:      response.RequestId,
+		Quota:          response.Quota,
+	})
+	return nil
 }
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rishijash/idk_terminal/configs"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+func TestSetupStepsStopUnderAutoConfirm(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []utils.SetupStep
+	}{
+		{"sequential step", []utils.SetupStep{{Command: "false", Description: "fails"}}},
+		{"plan step", []utils.SetupStep{
+			{Id: "ok", Command: "true", Description: "works"},
+			{Id: "fail", Command: "false", Description: "fails", DependsOn: []string{"ok"}},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			pwd := t.TempDir()
+			state := &utils.SetupState{ProjectType: "Test", RunCommand: "true"}
+			for _, step := range test.steps {
+				step.Status = utils.SetupStepPending
+				state.Steps = append(state.Steps, step)
+			}
+			h := NewRunHandler(&configs.Config{}, Options{OutputFormat: OutputText, AutoConfirm: true, MaxAutoConfirmRisk: utils.RiskMedium})
+
+			done := make(chan error, 1)
+			go func() {
+				done <- h.runSetupSteps(pwd, state, "")
+			}()
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Fatal("runSetupSteps() succeeded with a failing step")
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("runSetupSteps() kept retrying the failing step")
+			}
+		})
+	}
+}
+
+func TestSetupStepChecksNeedConfirmation(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps func(check string) []utils.SetupStep
+	}{
+		{"sequential step", func(check string) []utils.SetupStep {
+			return []utils.SetupStep{{Command: "true", Description: "works", Check: check}}
+		}},
+		{"plan step", func(check string) []utils.SetupStep {
+			return []utils.SetupStep{{Id: "a", Command: "true", Description: "works", Check: check, Parallel: true}}
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			pwd := t.TempDir()
+			marker := filepath.Join(pwd, "checked")
+			// the check is the risky part of the step, the command alone would be auto-confirmed
+			check := fmt.Sprintf("touch %s && rm -rf %s", marker, filepath.Join(pwd, "cache"))
+			state := &utils.SetupState{ProjectType: "Test", RunCommand: "true"}
+			for _, step := range test.steps(check) {
+				step.Status = utils.SetupStepPending
+				state.Steps = append(state.Steps, step)
+			}
+			h := NewRunHandler(&configs.Config{}, Options{OutputFormat: OutputText, AutoConfirm: true, MaxAutoConfirmRisk: utils.RiskMedium})
+
+			if err := h.runSetupSteps(pwd, state, ""); ExitCode(err) != ExitCancelled {
+				t.Errorf("runSetupSteps() error = %v, want it cancelled", err)
+			}
+			if _, err := os.Stat(marker); err == nil {
+				t.Error("the check ran before the step was confirmed")
+			}
+		})
+	}
+}
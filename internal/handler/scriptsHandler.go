@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+type ScriptsHandler struct {
+	options Options
+}
+
+func NewScriptsHandler(options Options) ScriptsHandler {
+	return ScriptsHandler{
+		options: options,
+	}
+}
+
+func (h ScriptsHandler) HandleList(ctx context.Context, tag string) error {
+	scripts, err := utils.ListLibraryScripts(h.options.ScriptLibraryDir, tag)
+	if err != nil {
+		return NewError("internal_error", "Failed to read the script library. Please try again!")
+	}
+
+	if h.options.IsJsonOutput() {
+		if scripts == nil {
+			scripts = []utils.LibraryScript{}
+		}
+		utils.PrintJson(scripts)
+		return nil
+	}
+
+	if len(scripts) == 0 {
+		output.Println("No saved scripts yet. Save one with the `save` option after a script prompt")
+		return nil
+	}
+	for _, script := range scripts {
+		line := fmt.Sprintf("%s (%s)", script.Name, script.Language)
+		if script.Description != "" {
+			line += " - " + script.Description
+		}
+		if len(script.Tags) > 0 {
+			line += fmt.Sprintf(" [%s]", strings.Join(script.Tags, ", "))
+		}
+		output.Println(line)
+	}
+	return nil
+}
+
+func (h ScriptsHandler) HandleShow(ctx context.Context, name string) error {
+	meta, script, err := utils.LoadLibraryScript(h.options.ScriptLibraryDir, name)
+	if err != nil {
+		return NewError("invalid_input", fmt.Sprintf("No saved script named `%s`", name), "List scripts: `idk scripts list`")
+	}
+
+	if h.options.IsJsonOutput() {
+		utils.PrintJson(struct {
+			*utils.LibraryScript
+			Script string `json:"script"`
+		}{meta, script})
+		return nil
+	}
+
+	output.Printf("Name: %s\n", meta.Name)
+	output.Printf("Language: %s\n", meta.Language)
+	if meta.Description != "" {
+		output.Printf("Description: %s\n", meta.Description)
+	}
+	if len(meta.Tags) > 0 {
+		output.Printf("Tags: %s\n", strings.Join(meta.Tags, ", "))
+	}
+	for _, param := range meta.Params {
+		switch {
+		case param.Required:
+			output.Printf("Param: %s (required)\n", param.Name)
+		default:
+			output.Printf("Param: %s (default: %s)\n", param.Name, param.Default)
+		}
+	}
+	output.Code(script, meta.Language)
+	return nil
+}
+
+func (h ScriptsHandler) HandleRun(ctx context.Context, name string, params []string) error {
+	meta, script, err := utils.LoadLibraryScript(h.options.ScriptLibraryDir, name)
+	if err != nil {
+		return NewError("invalid_input", fmt.Sprintf("No saved script named `%s`", name), "List scripts: `idk scripts list`")
+	}
+
+	// synced scripts come from the team, their parameters are checked again before they reach the environment
+	if err := utils.ValidateLibraryScript(*meta); err != nil {
+		return NewError("invalid_input", err.Error())
+	}
+	env, err := utils.ScriptParamEnv(*meta, params)
+	if err != nil {
+		return NewError("invalid_input", err.Error())
+	}
+	if h.options.IsJsonOutput() && !h.options.AutoConfirm {
+		return NewError("invalid_input", "Running a script with --output json needs --yes")
+	}
+
+	language := utils.DetectScriptLanguage(meta.Language, script)
+	risk := utils.AssessCommandRisk(script)
+	if !h.options.IsJsonOutput() {
+		output.Printf("Script `%s` (%s):\n", name, language.Name)
+		output.Code(script, language.Name)
+		for _, variable := range env {
+			output.Printf("Param: %s\n", variable)
+		}
+	}
+	printRisk(risk)
+	if h.options.confirm("Do you want me to execute the script?", []string{"y", "n"}, h.options.runRisk(risk)) != "y" {
+		output.Println("Script not run")
+		return errCancelled
+	}
+	if h.options.StrictScripts {
+		script, language = utils.StrictScript(script, language)
+	}
+
+	err = runRecorded(fmt.Sprintf("idk scripts run %s", name), "SCRIPT", script, language, func() error {
+		return h.options.executor(risk).RunScript(script, language, utils.ScriptRunOptions{
+			Timeout: h.options.ScriptTimeout,
+			Env:     env,
+		})
+	})
+	if err != nil {
+		return commandFailed("Script execution failed", err)
+	}
+	return nil
+}
+
+func (h ScriptsHandler) HandleRemove(ctx context.Context, name string) error {
+	if h.options.confirm(fmt.Sprintf("Remove script `%s`?", name), []string{"y", "n"}, utils.RiskMedium) != "y" {
+		output.Println("Script not removed")
+		return errCancelled
+	}
+
+	if err := utils.RemoveLibraryScript(h.options.ScriptLibraryDir, name); err != nil {
+		return NewError("invalid_input", fmt.Sprintf("No saved script named `%s`", name))
+	}
+	output.Printf("Script `%s` removed\n", name)
+	return nil
+}
+
+// HandleSync commits the local changes of a script library shared through git, pulls the team's
+// changes and pushes the local ones once they are confirmed
+func (h ScriptsHandler) HandleSync(ctx context.Context) error {
+	libraryDir := h.options.ScriptLibraryDir
+	if err := utils.PullScriptLibrary(libraryDir); err != nil {
+		return NewError("internal_error", err.Error())
+	}
+
+	changes := utils.OutgoingLibraryChanges(libraryDir)
+	if len(changes) == 0 {
+		output.Println("Script library synced")
+		return nil
+	}
+	output.Println("Changes to share with your team:")
+	for _, change := range changes {
+		output.Println("  " + change)
+	}
+	if h.options.confirm("Push them to the shared library?", []string{"y", "n"}, utils.RiskMedium) != "y" {
+		output.Println("Changes committed but not pushed. Push them with `idk scripts sync`")
+		return errCancelled
+	}
+	if err := utils.PushScriptLibrary(libraryDir); err != nil {
+		return NewError("internal_error", err.Error())
+	}
+	output.Println("Script library synced")
+	return nil
+}
+
+// HandleClone sets up a script library shared through git
+func (h ScriptsHandler) HandleClone(ctx context.Context, gitUrl string) error {
+	if err := utils.CloneScriptLibrary(gitUrl, h.options.ScriptLibraryDir); err != nil {
+		return NewError("internal_error", fmt.Sprintf("Failed to clone script library: %s", err))
+	}
+	output.Printf("Script library cloned into %s. Keep it up to date with `idk scripts sync`\n", h.options.ScriptLibraryDir)
+	return nil
+}
+
+// saveToLibrary asks for the name, description, tags and parameters of a generated script and saves it
+func saveToLibrary(script string, language utils.ScriptLanguage, options Options) error {
+	defaultName := fmt.Sprintf("idk_script_%s", time.Now().Format("2006-01-02_15-04-05"))
+	name := options.ask(fmt.Sprintf("Name (default: %s):", defaultName))
+	if name == "" {
+		name = defaultName
+	}
+	description := options.ask("Description (optional):")
+	tags := options.ask("Tags, comma separated (optional):")
+	params := options.ask("Parameters passed as environment variables, e.g. `host, port=8080` (optional):")
+
+	meta := utils.LibraryScript{
+		Name:        name,
+		Description: description,
+		Language:    language.Name,
+		Params:      utils.ParseScriptParams(params),
+		CreatedAt:   time.Now(),
+	}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			meta.Tags = append(meta.Tags, tag)
+		}
+	}
+
+	err := utils.SaveLibraryScript(options.ScriptLibraryDir, meta, script, false)
+	if errors.Is(err, utils.ErrLibraryScriptExists) {
+		if options.confirm(fmt.Sprintf("A script named `%s` already exists. Replace it?", name), []string{"n", "y"}, utils.RiskMedium) != "y" {
+			output.Println("Script not saved")
+			return nil
+		}
+		err = utils.SaveLibraryScript(options.ScriptLibraryDir, meta, script, true)
+	}
+	if err != nil {
+		return err
+	}
+	output.Printf("Script saved as `%s`. Run it with `idk scripts run %s`\n", name, name)
+	return nil
+}
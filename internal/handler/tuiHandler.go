@@ -0,0 +1,731 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/term"
+
+	"github.com/rishijash/idk_terminal/configs"
+	"github.com/rishijash/idk_terminal/internal/clients"
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+type TuiHandler struct {
+	config  *configs.Config
+	options Options
+}
+
+func NewTuiHandler(config *configs.Config, options Options) TuiHandler {
+	return TuiHandler{
+		config:  config,
+		options: options,
+	}
+}
+
+// HandleTui starts a full-screen session for prompts, the `idk --tui` counterpart of PromptHandler.
+// DebugHandler and RunHandler keep their line-based flow. A non-empty prompt is sent right away.
+func (h TuiHandler) HandleTui(ctx context.Context, prompt string) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return NewError("invalid_input", "--tui needs an interactive terminal")
+	}
+
+	token, err := loadToken()
+	if err != nil {
+		return err
+	}
+
+	model := newTuiModel(h, token)
+	var initCmds []tea.Cmd
+	if prompt != "" {
+		model.input.SetValue(prompt)
+		initCmds = append(initCmds, model.submitPrompt())
+	}
+	model.initCmds = initCmds
+
+	if _, err := tea.NewProgram(model, tea.WithAltScreen()).Run(); err != nil {
+		return NewError("internal_error", fmt.Sprintf("Something went wrong: %s", err))
+	}
+	return nil
+}
+
+type tuiMode int
+
+const (
+	// tuiModePrompt is typing a new prompt
+	tuiModePrompt tuiMode = iota
+	// tuiModeAction is looking at a generated command, script or plan
+	tuiModeAction
+	// tuiModeAnswer is answering a question asked by an action, like the name to save a script as
+	tuiModeAnswer
+	// tuiModeHistory is browsing previous runs
+	tuiModeHistory
+)
+
+type tuiModel struct {
+	handler  TuiHandler
+	token    string
+	initCmds []tea.Cmd
+
+	width  int
+	height int
+	mode   tuiMode
+
+	input textinput.Model
+	// question is what the answer mode asks for, "update" or "save"
+	question string
+	spinner  spinner.Model
+	// loading is shown next to the spinner while the backend or a command is busy
+	loading string
+	status  string
+
+	prompt     string
+	actionType string
+	code       string
+	language   utils.ScriptLanguage
+	risk       string
+	// baseRisk is the risk of the action before any edit, edits are assessed on top of it
+	baseRisk    string
+	explanation string
+	steps       []clients.PlanStep
+	// choices are the command and its alternatives, choice is the one shown
+	choices []clients.CommandAlternative
+	choice  int
+	// armed is set when a risky action was asked to run once and needs a second key press
+	armed bool
+
+	output      viewport.Model
+	outputLines []string
+	runErr      error
+	hasRun      bool
+	runEvents   chan tea.Msg
+
+	history      []utils.HistoryEntry
+	historyIndex int
+}
+
+type promptResponseMsg struct {
+	prompt   string
+	response *clients.PromptResponse
+	err      error
+	status   int
+}
+
+type debugResponseMsg struct {
+	response *clients.DebugCommandResponse
+	err      error
+	status   int
+}
+
+type outputLineMsg string
+
+type runDoneMsg struct {
+	err error
+}
+
+type editDoneMsg struct {
+	code string
+	err  error
+}
+
+func newTuiModel(h TuiHandler, token string) tuiModel {
+	input := textinput.New()
+	input.Placeholder = "What do you want to do?"
+	input.Prompt = "> "
+	input.Focus()
+
+	loadingSpinner := spinner.New()
+	loadingSpinner.Spinner = spinner.Line
+
+	m := tuiModel{
+		handler: h,
+		token:   token,
+		input:   input,
+		spinner: loadingSpinner,
+		output:  viewport.New(80, 5),
+	}
+	m.loadHistory()
+	return m
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return tea.Batch(append([]tea.Cmd{textinput.Blink}, m.initCmds...)...)
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.input.Width = msg.Width - 4
+		m.resizeOutput()
+		return m, nil
+
+	case spinner.TickMsg:
+		if m.loading == "" {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
+	case promptResponseMsg:
+		m.loading = ""
+		if code, messages := backendError(msg.status, msg.err); code != "" {
+			m.status = strings.Join(messages, ". ")
+			m.setMode(tuiModePrompt)
+			return m, nil
+		}
+		m.showResponse(msg.prompt, msg.response)
+		return m, nil
+
+	case debugResponseMsg:
+		m.loading = ""
+		if code, messages := backendError(msg.status, msg.err); code != "" {
+			m.status = strings.Join(messages, ". ")
+			return m, nil
+		}
+		m.explanation = msg.response.Response
+		m.status = "Debug explanation below"
+		return m, nil
+
+	case outputLineMsg:
+		m.appendOutput(string(msg))
+		return m, waitForRunEvent(m.runEvents)
+
+	case runDoneMsg:
+		m.loading = ""
+		m.runEvents = nil
+		m.hasRun = true
+		m.runErr = msg.err
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Failed: %s. Press d to debug", msg.err)
+		} else {
+			m.status = "Completed"
+		}
+		m.loadHistory()
+		return m, nil
+
+	case editDoneMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("Edit failed: %s", msg.err)
+			return m, nil
+		}
+		m.setCode(msg.code)
+		m.status = "Edited"
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+		switch m.mode {
+		case tuiModePrompt, tuiModeAnswer:
+			return m.updateInput(msg)
+		case tuiModeAction:
+			return m.updateAction(msg)
+		case tuiModeHistory:
+			return m.updateHistory(msg)
+		}
+	}
+
+	return m, nil
+}
+
+func (m tuiModel) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		if m.loading != "" || strings.TrimSpace(m.input.Value()) == "" {
+			return m, nil
+		}
+		if m.mode == tuiModePrompt {
+			cmd := m.submitPrompt()
+			return m, cmd
+		}
+		cmd := m.submitAnswer()
+		return m, cmd
+	case tea.KeyEsc:
+		if m.mode == tuiModeAnswer || m.code != "" {
+			m.setMode(tuiModeAction)
+			return m, nil
+		}
+		return m, tea.Quit
+	case tea.KeyTab:
+		if m.mode == tuiModePrompt && len(m.history) > 0 {
+			m.setMode(tuiModeHistory)
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m tuiModel) updateAction(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.loading != "" {
+		return m, nil
+	}
+
+	key := msg.String()
+	if key != "r" {
+		m.armed = false
+	}
+	switch key {
+	case "r":
+		return m.run()
+	case "c":
+		if err := clipboard.WriteAll(m.code); err != nil {
+			m.status = "Failed to copy to clipboard"
+		} else {
+			m.status = "Copied to clipboard"
+		}
+	case "s":
+		m.question = "save"
+		m.setMode(tuiModeAnswer)
+	case "u":
+		m.question = "update"
+		m.setMode(tuiModeAnswer)
+	case "e":
+		return m, m.edit()
+	case "d":
+		if !m.hasRun || m.runErr == nil {
+			m.status = "Nothing to debug, run it first"
+			return m, nil
+		}
+		cmd := m.debug()
+		return m, cmd
+	case "a":
+		if len(m.choices) > 1 {
+			m.choice = (m.choice + 1) % len(m.choices)
+			m.baseRisk = m.choices[m.choice].Risk
+			m.setCode(m.choices[m.choice].Command)
+			m.status = fmt.Sprintf("Alternative %d of %d", m.choice+1, len(m.choices))
+		}
+	case "n", "esc":
+		m.setMode(tuiModePrompt)
+	case "tab":
+		if len(m.history) > 0 {
+			m.setMode(tuiModeHistory)
+		}
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		m.output.LineUp(1)
+	case "down", "j":
+		m.output.LineDown(1)
+	}
+	return m, nil
+}
+
+func (m tuiModel) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.historyIndex > 0 {
+			m.historyIndex--
+		}
+	case "down", "j":
+		if m.historyIndex < len(m.history)-1 {
+			m.historyIndex++
+		}
+	case "enter":
+		entry := m.history[m.historyIndex]
+		actionType, language := entry.ActionType, utils.ShellLanguage
+		switch entry.ActionType {
+		case "SCRIPT":
+			if entry.Language == "" {
+				m.status = "This script was recorded without its language and can't be run again"
+				return m, nil
+			}
+			language = utils.DetectScriptLanguage(entry.Language, entry.Command)
+		case "PLAN":
+			// plans are recorded step by step, an entry is the command of one step
+			actionType = "COMMAND"
+		}
+		m.prompt = entry.Prompt
+		m.actionType = actionType
+		m.language = language
+		m.explanation = ""
+		m.steps = nil
+		m.choices = nil
+		m.hasRun = false
+		m.baseRisk = utils.RiskLow
+		m.setCode(entry.Command)
+		m.setMode(tuiModeAction)
+		m.status = fmt.Sprintf("Loaded from history, ran in %s", entry.Dir)
+	case "tab", "esc":
+		if m.code != "" {
+			m.setMode(tuiModeAction)
+		} else {
+			m.setMode(tuiModePrompt)
+		}
+	case "q":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m *tuiModel) setMode(mode tuiMode) {
+	m.mode = mode
+	m.armed = false
+	m.input.Reset()
+	switch mode {
+	case tuiModePrompt:
+		m.input.Placeholder = "What do you want to do?"
+		m.input.Focus()
+	case tuiModeAnswer:
+		if m.question == "save" {
+			m.input.Placeholder = "Name to save the script as"
+		} else {
+			m.input.Placeholder = "What do you want to change?"
+		}
+		m.input.Focus()
+	default:
+		m.input.Blur()
+	}
+}
+
+func (m *tuiModel) startLoading(text string) tea.Cmd {
+	m.loading = text
+	m.status = ""
+	return m.spinner.Tick
+}
+
+func (m *tuiModel) submitPrompt() tea.Cmd {
+	prompt := strings.TrimSpace(m.input.Value())
+	m.input.Blur()
+	return tea.Batch(m.startLoading("Thinking..."), m.requestPrompt(prompt, prompt, ""))
+}
+
+// requestPrompt asks the backend in the background, existingScript is the code being updated
+func (m tuiModel) requestPrompt(displayPrompt string, prompt string, existingScript string) tea.Cmd {
+	config := m.handler.config
+	token := m.token
+	return func() tea.Msg {
+		response, err, status := processPrompt(config, prompt, "", existingScript, nil, token)
+		return promptResponseMsg{prompt: displayPrompt, response: response, err: err, status: status}
+	}
+}
+
+func (m *tuiModel) submitAnswer() tea.Cmd {
+	answer := strings.TrimSpace(m.input.Value())
+	question := m.question
+	m.setMode(tuiModeAction)
+
+	switch question {
+	case "save":
+		meta := utils.LibraryScript{Name: answer, Language: m.language.Name, CreatedAt: time.Now()}
+		if err := utils.SaveLibraryScript(m.handler.options.ScriptLibraryDir, meta, m.code, false); err != nil {
+			m.status = fmt.Sprintf("Failed to save: %s", err)
+		} else {
+			m.status = fmt.Sprintf("Saved, run it with `idk scripts run %s`", answer)
+		}
+		return nil
+	default:
+		return tea.Batch(m.startLoading("Updating..."), m.requestPrompt(m.prompt, answer, m.code))
+	}
+}
+
+func (m *tuiModel) showResponse(prompt string, response *clients.PromptResponse) {
+	m.prompt = prompt
+	m.actionType = response.ActionType
+	m.explanation = response.Explanation
+	m.steps = nil
+	m.choices = nil
+	m.choice = 0
+	m.hasRun = false
+	m.runErr = nil
+	m.language = utils.ShellLanguage
+
+	switch response.ActionType {
+	case "COMMAND", "COMMANDFROMREADME":
+		m.choices = []clients.CommandAlternative{{
+			Command: response.Response,
+			Note:    "recommended",
+			Risk:    utils.MaxRisk(response.Risk, utils.AssessCommandRisk(response.Response)),
+		}}
+		for _, alternative := range response.Alternatives {
+			alternative.Risk = utils.MaxRisk(alternative.Risk, utils.AssessCommandRisk(alternative.Command))
+			m.choices = append(m.choices, alternative)
+		}
+		m.baseRisk = m.choices[0].Risk
+		m.setCode(response.Response)
+	case "SCRIPT":
+		m.language = utils.DetectScriptLanguage(response.Language, response.Response)
+		m.baseRisk = response.Risk
+		m.setCode(response.Response)
+	case "PLAN":
+		m.steps = response.Steps
+		m.baseRisk = utils.RiskLow
+		var commands []string
+		for _, step := range response.Steps {
+			commands = append(commands, fmt.Sprintf("# %s\n%s", step.Description, step.Command))
+		}
+		m.setCode(strings.Join(commands, "\n"))
+		if m.explanation == "" {
+			m.explanation = response.Response
+		}
+	default:
+		// plain answers have nothing to run
+		m.code = ""
+		m.explanation = response.Response
+		m.setMode(tuiModePrompt)
+		return
+	}
+	m.setMode(tuiModeAction)
+}
+
+// setCode replaces the command, script or plan, for example after editing it, and assesses its risk again
+func (m *tuiModel) setCode(code string) {
+	m.code = code
+	if m.actionType != "PLAN" {
+		m.risk = utils.MaxRisk(m.baseRisk, utils.AssessCommandRisk(code))
+		return
+	}
+
+	m.steps = planStepsFromCode(code, m.steps)
+	m.risk = m.baseRisk
+	for _, step := range m.steps {
+		m.risk = utils.MaxRisk(m.risk, planStepRisk(step))
+	}
+}
+
+// planStepsFromCode reads the steps of a plan back from the code shown for it, where every step is a
+// `# description` line followed by its command. Steps keep the precondition and verification of the
+// step with the same description in previous.
+func planStepsFromCode(code string, previous []clients.PlanStep) []clients.PlanStep {
+	var steps []clients.PlanStep
+	var lines []string
+	addStep := func(description string) {
+		if len(lines) > 0 {
+			step := clients.PlanStep{Description: description, Command: strings.TrimSpace(strings.Join(lines, "\n"))}
+			for _, previousStep := range previous {
+				if previousStep.Description == description {
+					step.Precondition, step.Verification = previousStep.Precondition, previousStep.Verification
+					break
+				}
+			}
+			if step.Command != "" {
+				steps = append(steps, step)
+			}
+		}
+		lines = nil
+	}
+
+	description := ""
+	for _, line := range strings.Split(code, "\n") {
+		if strings.HasPrefix(line, "# ") {
+			addStep(description)
+			description = strings.TrimPrefix(line, "# ")
+			continue
+		}
+		lines = append(lines, line)
+	}
+	addStep(description)
+	return steps
+}
+
+func (m *tuiModel) appendOutput(line string) {
+	m.outputLines = append(m.outputLines, line)
+	// keep the pane from growing forever on chatty commands
+	if len(m.outputLines) > 1000 {
+		m.outputLines = m.outputLines[len(m.outputLines)-1000:]
+	}
+	m.output.SetContent(strings.Join(m.outputLines, "\n"))
+	m.output.GotoBottom()
+}
+
+func (m *tuiModel) loadHistory() {
+	history, err := utils.LoadHistory()
+	if err != nil {
+		return
+	}
+	// newest first
+	m.history = nil
+	for i := len(history) - 1; i >= 0 && len(m.history) < 50; i-- {
+		m.history = append(m.history, history[i])
+	}
+	m.historyIndex = 0
+}
+
+// run executes the current action. Anything that isn't low risk needs a second key press.
+// Commands stream into the output pane. Scripts and sandboxed runs need the terminal for
+// themselves, so the TUI steps aside while they run.
+func (m tuiModel) run() (tea.Model, tea.Cmd) {
+	options := m.handler.options
+	risk := options.runRisk(m.risk)
+	if risk != utils.RiskLow && !m.armed {
+		m.armed = true
+		m.status = fmt.Sprintf("This is a %s risk action. Press r again to run it", risk)
+		return m, nil
+	}
+	m.armed = false
+	m.outputLines = nil
+	m.output.SetContent("")
+
+	if m.actionType == "SCRIPT" || options.SandboxBackend != "" {
+		return m, m.runInTerminal()
+	}
+
+	var commands []string
+	if m.actionType == "PLAN" {
+		if len(m.steps) == 0 {
+			m.status = "The plan has no steps to run"
+			return m, nil
+		}
+		for _, step := range m.steps {
+			commands = append(commands, step.Precondition, step.Command, step.Verification)
+		}
+	} else {
+		commands = []string{m.code}
+	}
+
+	m.runEvents = make(chan tea.Msg, 64)
+	go streamCommands(m.prompt, m.actionType, commands, m.runEvents)
+	return m, tea.Batch(m.startLoading("Running..."), waitForRunEvent(m.runEvents))
+}
+
+// streamCommands runs commands one after the other, stopping at the first failure, and sends
+// their combined output line by line. Empty commands are skipped.
+func streamCommands(prompt string, actionType string, commands []string, events chan<- tea.Msg) {
+	defer close(events)
+
+	for _, command := range commands {
+		if command == "" {
+			continue
+		}
+		events <- outputLineMsg("$ " + command)
+
+		recording := startRecording(command, utils.ShellLanguage)
+		for _, note := range recording.notes {
+			events <- outputLineMsg(note)
+		}
+
+		err := streamCommand(command, events)
+		recording.finish(prompt, actionType, command, err)
+		if recording.snapshotId != "" {
+			events <- outputLineMsg(fmt.Sprintf("Undo with `idk undo %s`", recording.snapshotId))
+		}
+		if err != nil {
+			events <- runDoneMsg{err: err}
+			return
+		}
+	}
+	events <- runDoneMsg{}
+}
+
+func streamCommand(command string, events chan<- tea.Msg) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		events <- outputLineMsg(scanner.Text())
+	}
+	return cmd.Wait()
+}
+
+func waitForRunEvent(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-events
+		if !ok {
+			return runDoneMsg{}
+		}
+		return msg
+	}
+}
+
+// terminalRun runs a function with the terminal handed over by bubbletea
+type terminalRun struct {
+	run func() error
+}
+
+func (t terminalRun) Run() error {
+	err := t.run()
+	output.Printf("\nPress enter to go back to idk")
+	readLine()
+	return err
+}
+
+func (t terminalRun) SetStdin(io.Reader)  {}
+func (t terminalRun) SetStdout(io.Writer) {}
+func (t terminalRun) SetStderr(io.Writer) {}
+
+func (m tuiModel) runInTerminal() tea.Cmd {
+	options := m.handler.options
+	prompt, actionType, code, language, risk := m.prompt, m.actionType, m.code, m.language, m.risk
+
+	run := terminalRun{run: func() error {
+		return runRecorded(prompt, actionType, code, language, func() error {
+			if actionType != "SCRIPT" {
+				return options.executor(risk).RunCommand(code)
+			}
+			script, scriptLanguage := code, language
+			if options.StrictScripts {
+				script, scriptLanguage = utils.StrictScript(script, scriptLanguage)
+			}
+			return options.executor(risk).RunScript(script, scriptLanguage, utils.ScriptRunOptions{Timeout: options.ScriptTimeout})
+		})
+	}}
+	return tea.Exec(run, func(err error) tea.Msg {
+		return runDoneMsg{err: err}
+	})
+}
+
+// edit opens the command or script in $EDITOR
+func (m tuiModel) edit() tea.Cmd {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "idk-edit-*"+m.language.Extension)
+	if err != nil {
+		return func() tea.Msg { return editDoneMsg{err: err} }
+	}
+	_, err = file.WriteString(m.code)
+	file.Close()
+	if err != nil {
+		return func() tea.Msg { return editDoneMsg{err: err} }
+	}
+
+	return tea.ExecProcess(exec.Command(editor, file.Name()), func(err error) tea.Msg {
+		defer os.Remove(file.Name())
+		if err != nil {
+			return editDoneMsg{err: err}
+		}
+		code, err := os.ReadFile(file.Name())
+		return editDoneMsg{code: strings.TrimRight(string(code), "\n"), err: err}
+	})
+}
+
+func (m *tuiModel) debug() tea.Cmd {
+	config := m.handler.config
+	token := m.token
+	code, runErr := m.code, m.runErr
+	return tea.Batch(m.startLoading("Debugging..."), func() tea.Msg {
+		response, err, status := clients.ProcessDebugCommand(code, runtime.GOOS, runErr, token, config.IdkBackendBaseUrl)
+		return debugResponseMsg{response: response, err: err, status: status}
+	})
+}
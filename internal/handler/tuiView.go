@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+var (
+	tuiTitleStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	tuiPaneStyle   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("8")).Padding(0, 1)
+	tuiFocusStyle  = tuiPaneStyle.Copy().BorderForeground(lipgloss.Color("12"))
+	tuiMutedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	tuiStatusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+	tuiSelectStyle = lipgloss.NewStyle().Reverse(true)
+	tuiRiskStyles  = map[string]lipgloss.Style{
+		utils.RiskLow:    lipgloss.NewStyle().Foreground(lipgloss.Color("10")),
+		utils.RiskMedium: lipgloss.NewStyle().Foreground(lipgloss.Color("11")),
+		utils.RiskHigh:   lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Bold(true),
+	}
+)
+
+// historyWidth is the width of the history pane, it is hidden on narrow terminals
+const historyWidth = 36
+
+func (m tuiModel) View() string {
+	if m.width == 0 {
+		return ""
+	}
+
+	mainWidth := m.width
+	showHistory := m.width >= 100 || m.mode == tuiModeHistory
+	if showHistory {
+		mainWidth = m.width - historyWidth
+		if m.width < 100 {
+			mainWidth = 0
+		}
+	}
+
+	var panes []string
+	if mainWidth > 0 {
+		panes = append(panes, m.mainView(mainWidth))
+	}
+	if showHistory {
+		panes = append(panes, m.historyView(m.width-mainWidth))
+	}
+	body := lipgloss.JoinHorizontal(lipgloss.Top, panes...)
+
+	return lipgloss.JoinVertical(lipgloss.Left, body, m.statusView(), m.keysView())
+}
+
+func (m tuiModel) mainView(width int) string {
+	innerWidth := width - 4
+	var sections []string
+
+	inputStyle := tuiPaneStyle
+	if m.mode == tuiModePrompt || m.mode == tuiModeAnswer {
+		inputStyle = tuiFocusStyle
+	}
+	title := "Prompt"
+	if m.mode == tuiModeAnswer {
+		title = strings.ToUpper(m.question[:1]) + m.question[1:]
+	}
+	inputView := m.input.View()
+	if m.mode != tuiModePrompt && m.mode != tuiModeAnswer && m.prompt != "" {
+		inputView = m.prompt
+	}
+	sections = append(sections, inputStyle.Width(width-2).Render(tuiTitleStyle.Render(title)+"\n"+inputView))
+
+	if m.code != "" {
+		header := "Command"
+		switch m.actionType {
+		case "SCRIPT":
+			header = fmt.Sprintf("Script (%s)", m.language.Name)
+		case "PLAN":
+			header = fmt.Sprintf("Plan (%d steps)", len(m.steps))
+		}
+		if len(m.choices) > 1 {
+			header += fmt.Sprintf(" %d/%d", m.choice+1, len(m.choices))
+			if note := m.choices[m.choice].Note; note != "" {
+				header += " - " + note
+			}
+		}
+		riskStyle, ok := tuiRiskStyles[m.risk]
+		if !ok {
+			riskStyle = tuiMutedStyle
+		}
+		header = tuiTitleStyle.Render(header) + "  " + riskStyle.Render("risk: "+m.risk)
+
+		codeStyle := tuiPaneStyle
+		if m.mode == tuiModeAction {
+			codeStyle = tuiFocusStyle
+		}
+		sections = append(sections, codeStyle.Width(width-2).Render(header+"\n"+output.Highlight(m.code, m.language.Name)))
+	}
+
+	if m.explanation != "" {
+		explanation := lipgloss.NewStyle().Width(innerWidth).Render(output.Markdown(m.explanation))
+		sections = append(sections, tuiPaneStyle.Width(width-2).Render(tuiTitleStyle.Render("Explanation")+"\n"+explanation))
+	}
+
+	if len(m.outputLines) > 0 {
+		sections = append(sections, tuiPaneStyle.Width(width-2).Render(tuiTitleStyle.Render("Output")+"\n"+m.output.View()))
+	}
+
+	return strings.Join(sections, "\n")
+}
+
+func (m tuiModel) historyView(width int) string {
+	style := tuiPaneStyle
+	if m.mode == tuiModeHistory {
+		style = tuiFocusStyle
+	}
+
+	lines := []string{tuiTitleStyle.Render("History")}
+	if len(m.history) == 0 {
+		lines = append(lines, tuiMutedStyle.Render("Nothing ran yet"))
+	}
+	maxLines := m.height - 5
+	for i, entry := range m.history {
+		if i >= maxLines {
+			break
+		}
+		line := truncate(entry.Command, width-6)
+		if entry.ExitCode != 0 {
+			line = "x " + line
+		} else {
+			line = "  " + line
+		}
+		if m.mode == tuiModeHistory && i == m.historyIndex {
+			line = tuiSelectStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return style.Width(width - 2).Render(strings.Join(lines, "\n"))
+}
+
+func (m tuiModel) statusView() string {
+	if m.loading != "" {
+		return " " + m.spinner.View() + " " + m.loading
+	}
+	return " " + tuiStatusStyle.Render(m.status)
+}
+
+func (m tuiModel) keysView() string {
+	var keys []string
+	switch m.mode {
+	case tuiModePrompt:
+		keys = []string{"enter send", "tab history", "esc quit"}
+	case tuiModeAnswer:
+		keys = []string{"enter ok", "esc back"}
+	case tuiModeAction:
+		keys = []string{"r run", "c copy", "s save", "u update", "e edit", "d debug"}
+		if len(m.choices) > 1 {
+			keys = append(keys, "a alternative")
+		}
+		keys = append(keys, "n new prompt", "tab history", "q quit")
+	case tuiModeHistory:
+		keys = []string{"↑/↓ move", "enter load", "tab back", "q quit"}
+	}
+	return " " + tuiMutedStyle.Render(strings.Join(keys, " · "))
+}
+
+// resizeOutput fits the output pane next to the history pane, using a third of the screen height
+func (m *tuiModel) resizeOutput() {
+	m.output.Width = m.width - 4
+	if m.width >= 100 {
+		m.output.Width -= historyWidth
+	}
+	m.output.Height = max(3, m.height/3)
+}
+
+func truncate(s string, width int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	if width <= 1 || len(s) <= width {
+		return s
+	}
+	return s[:width-1] + "…"
+}
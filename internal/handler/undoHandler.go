@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+type UndoHandler struct {
+	options Options
+}
+
+func NewUndoHandler(options Options) UndoHandler {
+	return UndoHandler{
+		options: options,
+	}
+}
+
+// HandleUndo restores the snapshot with the given id, or the latest one that was not undone yet
+func (h UndoHandler) HandleUndo(ctx context.Context, id string) error {
+	var snapshot *utils.Snapshot
+	var err error
+	if id != "" {
+		if !utils.IsHistoryId(id) {
+			return NewError("invalid_input", fmt.Sprintf("`%s` is not a snapshot id, ids look like 20240102-150405-a1B2", id), "List snapshots: `idk undo --list`")
+		}
+		snapshot, err = utils.LoadSnapshot(id)
+		if err != nil {
+			return NewError("invalid_input", fmt.Sprintf("No snapshot found with id `%s`", id), "List snapshots: `idk undo --list`")
+		}
+	} else {
+		snapshot, err = latestSnapshot()
+		if err != nil || snapshot == nil {
+			return NewError("invalid_input", "Nothing to undo")
+		}
+	}
+
+	if snapshot.RestoredAt != nil {
+		return NewError("invalid_input", fmt.Sprintf("Snapshot `%s` was already restored on %s", snapshot.Id, snapshot.RestoredAt.Format(time.DateTime)))
+	}
+
+	output.Printf("Undo `%s` from %s\n", snapshot.Command, snapshot.CreatedAt.Format(time.DateTime))
+	for _, file := range snapshot.Files {
+		if file.Existed {
+			output.Printf("  restore %s\n", file.Path)
+		} else {
+			output.Printf("  remove  %s\n", file.Path)
+		}
+	}
+
+	// restoring overwrites whatever happened to these files since the snapshot
+	if h.options.confirm("Continue?", []string{"y", "n"}, utils.RiskMedium) != "y" {
+		output.Println("Undo canceled")
+		return errCancelled
+	}
+
+	if err := utils.RestoreSnapshot(snapshot); err != nil {
+		return NewError("internal_error", fmt.Sprintf("Failed to restore snapshot: %s", err))
+	}
+	output.Println("Undo completed")
+	return nil
+}
+
+// HandleListSnapshots prints the snapshots that can be undone
+func (h UndoHandler) HandleListSnapshots(ctx context.Context) error {
+	snapshots, err := utils.ListSnapshots()
+	if err != nil {
+		return NewError("internal_error", "Something went wrong. Please try again!")
+	}
+
+	if h.options.IsJsonOutput() {
+		utils.PrintJson(snapshots)
+		return nil
+	}
+
+	if len(snapshots) == 0 {
+		output.Println("No snapshots yet")
+		return nil
+	}
+	for _, snapshot := range snapshots {
+		status := ""
+		if snapshot.RestoredAt != nil {
+			status = " (undone)"
+		}
+		output.Printf("%s  %s%s\n", snapshot.Id, snapshot.Command, status)
+	}
+	return nil
+}
+
+func latestSnapshot() (*utils.Snapshot, error) {
+	snapshots, err := utils.ListSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.RestoredAt == nil {
+			return &snapshot, nil
+		}
+	}
+	return nil, nil
+}
+
+// runRecorded snapshots the files a shell command or script is about to modify, runs it and
+// adds it to the history so it can be undone with `idk undo`. Scripts in other languages
+// can't be analyzed and are recorded without a snapshot.
+func runRecorded(prompt string, actionType string, command string, language utils.ScriptLanguage, run func() error) error {
+	recording := startRecording(command, language)
+	for _, note := range recording.notes {
+		output.Println(note)
+	}
+
+	err := run()
+
+	recording.finish(prompt, actionType, command, err)
+	if recording.snapshotId != "" {
+		output.Printf("Undo with `idk undo %s`\n", recording.snapshotId)
+	}
+	return err
+}
+
+// recording is a run that is added to the history once it finishes
+type recording struct {
+	historyId  string
+	dir        string
+	language   string
+	snapshotId string
+	// notes are warnings about files that could not be snapshotted
+	notes []string
+}
+
+func startRecording(command string, language utils.ScriptLanguage) recording {
+	r := recording{historyId: utils.NewHistoryId(), language: language.Name}
+	r.dir, _ = os.Getwd()
+	if language.Name != utils.ShellLanguage.Name && language.Name != utils.BashLanguage.Name {
+		return r
+	}
+
+	paths := utils.ModifiedPaths(command, r.dir)
+	if len(paths) == 0 {
+		return r
+	}
+	snapshot, skipped, err := utils.TakeSnapshot(r.historyId, command, paths)
+	if err != nil {
+		r.notes = append(r.notes, fmt.Sprintf("Failed to snapshot files, this can't be undone: %s", err))
+		return r
+	}
+	r.snapshotId = snapshot.Id
+	for _, path := range skipped {
+		r.notes = append(r.notes, fmt.Sprintf("%s %s and can't be undone", path.Path, path.Reason))
+	}
+	return r
+}
+
+func (r recording) finish(prompt string, actionType string, command string, err error) {
+	_ = utils.AppendHistory(utils.HistoryEntry{
+		Id:         r.historyId,
+		Time:       time.Now(),
+		Prompt:     prompt,
+		ActionType: actionType,
+		Command:    command,
+		Language:   r.language,
+		Dir:        r.dir,
+		ExitCode:   utils.ExitCode(err),
+		SnapshotId: r.snapshotId,
+	})
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rishijash/idk_terminal/configs"
+	"github.com/rishijash/idk_terminal/internal/clients"
+	"github.com/rishijash/idk_terminal/internal/output"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+// installScriptUrl installs the latest release, for builds that can't verify updates themselves
+const installScriptUrl = "https://idk-cli.github.io/scripts/install.sh"
+
+// versionCheckTimeout keeps `idk version` fast when the release server is unreachable
+const versionCheckTimeout = 3 * time.Second
+
+type UpdateHandler struct {
+	config  *configs.Config
+	options Options
+}
+
+func NewUpdateHandler(config *configs.Config, options Options) UpdateHandler {
+	return UpdateHandler{
+		config:  config,
+		options: options,
+	}
+}
+
+// versionResult is the JSON object printed by `idk version` in JSON output mode
+type versionResult struct {
+	Version         string `json:"version"`
+	Os              string `json:"os"`
+	Arch            string `json:"arch"`
+	Channel         string `json:"channel"`
+	LatestVersion   string `json:"latestVersion,omitempty"`
+	UpdateAvailable bool   `json:"updateAvailable"`
+	CheckError      string `json:"checkError,omitempty"`
+	// CanSelfUpdate is false for builds without a release key, which refuse `idk self update`
+	CanSelfUpdate bool `json:"canSelfUpdate"`
+}
+
+// HandleVersion prints the installed version and whether channel has a newer release
+func (h UpdateHandler) HandleVersion(ctx context.Context, channel string) error {
+	result := versionResult{Version: configs.Version, Os: runtime.GOOS, Arch: runtime.GOARCH, Channel: channel,
+		CanSelfUpdate: configs.ReleasePublicKey != ""}
+
+	manifest, err := clients.FetchReleaseManifest(h.config.ReleaseBaseUrl, channel, versionCheckTimeout)
+	if err != nil {
+		result.CheckError = err.Error()
+	} else if release, _ := findRelease(manifest, ""); release != nil {
+		result.LatestVersion = release.Version
+		result.UpdateAvailable = utils.CompareVersions(release.Version, configs.Version) > 0
+	}
+
+	if h.options.IsJsonOutput() {
+		utils.PrintJson(result)
+		return nil
+	}
+
+	output.Printf("idk %s (%s/%s)\n", result.Version, result.Os, result.Arch)
+	if !result.CanSelfUpdate {
+		output.Warnln(fmt.Sprintf("This build has no release key and can't verify updates, `idk self update` is disabled. Install a release build with `curl -o- %s | bash`", installScriptUrl))
+	}
+	switch {
+	case result.CheckError != "":
+		output.Warnln("Could not check for updates: " + result.CheckError)
+	case result.UpdateAvailable && result.CanSelfUpdate:
+		output.Printf("Update available: %s on the %s channel. Update with `idk self update`\n", result.LatestVersion, channel)
+	case result.UpdateAvailable:
+		output.Printf("Update available: %s on the %s channel\n", result.LatestVersion, channel)
+	case result.LatestVersion != "":
+		output.Printf("Up to date with the %s channel\n", channel)
+	}
+	return nil
+}
+
+// HandleSelfUpdate installs version from channel, or the latest release of channel if version is empty.
+// The download is only installed if its checksum and signature match. Older versions are only installed
+// when version pins one, after asking.
+func (h UpdateHandler) HandleSelfUpdate(ctx context.Context, channel string, version string) error {
+	if configs.ReleasePublicKey == "" {
+		return NewError("update_failed", "This build of idk has no release key to verify updates with, so it won't update itself",
+			fmt.Sprintf("Reinstall a release build with `curl -o- %s | bash`", installScriptUrl))
+	}
+
+	loadingSpinner := h.options.startSpinner("Checking for updates..")
+	manifest, err := clients.FetchReleaseManifest(h.config.ReleaseBaseUrl, channel, 30*time.Second)
+	loadingSpinner.Stop()
+	if err != nil {
+		return NewError("update_failed", fmt.Sprintf("Could not check for updates: %s", err))
+	}
+
+	release, binary := findRelease(manifest, version)
+	if release == nil {
+		if version != "" {
+			return NewError("invalid_input", fmt.Sprintf("No release %s on the %s channel", version, channel))
+		}
+		return NewError("update_failed", fmt.Sprintf("The %s channel has no release for %s/%s", channel, runtime.GOOS, runtime.GOARCH))
+	}
+	if binary == nil {
+		return NewError("update_failed", fmt.Sprintf("Release %s has no binary for %s/%s", release.Version, runtime.GOOS, runtime.GOARCH))
+	}
+
+	comparison := utils.CompareVersions(release.Version, configs.Version)
+	if comparison == 0 || (version == "" && comparison < 0) {
+		output.Printf("idk %s is up to date\n", configs.Version)
+		return nil
+	}
+
+	if release.Notes != "" {
+		output.Println(output.Markdown(release.Notes))
+	}
+	question := fmt.Sprintf("Update idk from %s to %s?", configs.Version, release.Version)
+	risk := utils.RiskLow
+	if comparison < 0 {
+		// only an explicit --version downgrades, older releases may lack fixes the installed one has
+		output.Warnln(fmt.Sprintf("idk %s is older than the installed %s. `idk self rollback` goes back to the version replaced by the last update", release.Version, configs.Version))
+		question = fmt.Sprintf("Downgrade idk from %s to %s?", configs.Version, release.Version)
+		risk = utils.RiskMedium
+	}
+	printRisk(risk)
+	if h.options.confirm(question, []string{"y", "n"}, risk) != "y" {
+		output.Println("Update canceled")
+		return errCancelled
+	}
+
+	loadingSpinner = h.options.startSpinner(fmt.Sprintf("Downloading idk %s..", release.Version))
+	data, err := downloadVerifiedRelease(h.config.ReleaseBaseUrl, channel, *release, *binary, configs.ReleasePublicKey)
+	loadingSpinner.Stop()
+	if err != nil {
+		return err
+	}
+	if err := utils.InstallExecutable(data, configs.Version); err != nil {
+		return NewError("update_failed", fmt.Sprintf("Failed to install the update: %s", err))
+	}
+
+	output.Printf("idk %s installed. Roll back with `idk self rollback`\n", release.Version)
+	return nil
+}
+
+// downloadVerifiedRelease downloads binary of release and returns it only if it is signed by publicKey
+// for the version of release and this platform
+func downloadVerifiedRelease(releaseBaseUrl string, channel string, release clients.Release, binary clients.ReleaseBinary, publicKey string) ([]byte, error) {
+	data, err := clients.DownloadReleaseBinary(releaseBaseUrl, channel, binary)
+	if err != nil {
+		return nil, NewError("update_failed", fmt.Sprintf("Download failed: %s", err))
+	}
+	if err := utils.VerifyReleaseBinary(data, release.Version, binary.Os, binary.Arch, binary.Sha256, binary.Signature, publicKey); err != nil {
+		return nil, NewError("update_failed", fmt.Sprintf("Update rejected: %s", err))
+	}
+	return data, nil
+}
+
+// HandleRollback reinstalls the version replaced by the last update
+func (h UpdateHandler) HandleRollback(ctx context.Context) error {
+	version, err := utils.RollbackExecutable(configs.Version)
+	if err != nil {
+		return NewError("update_failed", fmt.Sprintf("Rollback failed: %s", err))
+	}
+	if version == "" {
+		output.Println("Rolled back to the previous version")
+		return nil
+	}
+	output.Printf("Rolled back to idk %s\n", version)
+	return nil
+}
+
+// findRelease returns the release with version and its binary for this platform. Without a version
+// it returns the newest release that has a binary for this platform.
+func findRelease(manifest *clients.ReleaseManifest, version string) (*clients.Release, *clients.ReleaseBinary) {
+	var found *clients.Release
+	var foundBinary *clients.ReleaseBinary
+	for i := range manifest.Releases {
+		release := &manifest.Releases[i]
+		binary := releaseBinary(release)
+
+		if version != "" {
+			if strings.TrimPrefix(release.Version, "v") == strings.TrimPrefix(version, "v") {
+				return release, binary
+			}
+			continue
+		}
+		if binary != nil && (found == nil || utils.CompareVersions(release.Version, found.Version) > 0) {
+			found, foundBinary = release, binary
+		}
+	}
+	return found, foundBinary
+}
+
+func releaseBinary(release *clients.Release) *clients.ReleaseBinary {
+	for i, binary := range release.Binaries {
+		if binary.Os == runtime.GOOS && binary.Arch == runtime.GOARCH {
+			return &release.Binaries[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/rishijash/idk_terminal/internal/clients"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+func TestDownloadVerifiedRelease(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(publicKey)
+	binaries := map[string][]byte{"/idk_1.0.0": []byte("idk 1.0.0"), "/idk_1.1.0": []byte("idk 1.1.0")}
+	// signedBinary lists the binary at path as the release version, signed for signedVersion
+	signedBinary := func(path string, signedVersion string) clients.ReleaseBinary {
+		checksum := sha256.Sum256(binaries[path])
+		sha256Hex := hex.EncodeToString(checksum[:])
+		message := utils.ReleaseSignedMessage(signedVersion, runtime.GOOS, runtime.GOARCH, sha256Hex)
+		return clients.ReleaseBinary{
+			Os:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			Url:       path[1:],
+			Sha256:    sha256Hex,
+			Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, message)),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		release clients.Release
+		wantErr bool
+	}{
+		{"signed release", clients.Release{Version: "1.1.0", Binaries: []clients.ReleaseBinary{signedBinary("/idk_1.1.0", "1.1.0")}}, false},
+		{"old binary served as a newer release", clients.Release{Version: "1.2.0", Binaries: []clients.ReleaseBinary{signedBinary("/idk_1.0.0", "1.0.0")}}, true},
+		{"missing binary", clients.Release{Version: "1.1.0", Binaries: []clients.ReleaseBinary{{Os: runtime.GOOS, Arch: runtime.GOARCH, Url: "missing"}}}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/stable.json" {
+					_ = json.NewEncoder(w).Encode(clients.ReleaseManifest{Channel: "stable", Releases: []clients.Release{test.release}})
+					return
+				}
+				data, ok := binaries[r.URL.Path]
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				_, _ = w.Write(data)
+			}))
+			defer server.Close()
+
+			manifest, err := clients.FetchReleaseManifest(server.URL, "stable", time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			release, binary := findRelease(manifest, "")
+			if release == nil || binary == nil {
+				t.Fatalf("findRelease() found no release for %s/%s", runtime.GOOS, runtime.GOARCH)
+			}
+
+			data, err := downloadVerifiedRelease(server.URL, "stable", *release, *binary, key)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("downloadVerifiedRelease() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err == nil && string(data) != "idk "+release.Version {
+				t.Errorf("downloadVerifiedRelease() = %q", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package output
+
+import (
+	"slices"
+	"strings"
+)
+
+var shellKeywords = []string{"if", "then", "else", "elif", "fi", "for", "while", "do", "done", "case", "esac", "in", "function", "return", "export", "local", "set"}
+
+var languageKeywords = map[string][]string{
+	"python": {"def", "class", "import", "from", "return", "if", "elif", "else", "for", "while", "in", "with", "as", "try", "except", "finally", "raise", "not", "and", "or", "None", "True", "False"},
+	"node":   {"const", "let", "var", "function", "return", "if", "else", "for", "while", "of", "in", "async", "await", "require", "import", "from", "new", "try", "catch", "throw"},
+	"go":     {"package", "import", "func", "return", "if", "else", "for", "range", "var", "const", "type", "struct", "defer", "go", "nil", "err"},
+}
+
+// languageAliases maps markdown code fence languages to the names scripts use
+var languageAliases = map[string]string{
+	"py":         "python",
+	"python3":    "python",
+	"js":         "node",
+	"javascript": "node",
+	"golang":     "go",
+}
+
+// Highlight colors the comments, strings and keywords of a command or script. Unknown
+// languages are highlighted like shell.
+func Highlight(code string, language string) string {
+	if !ColorEnabled(Stdout) {
+		return code
+	}
+
+	language = strings.ToLower(language)
+	if alias, ok := languageAliases[language]; ok {
+		language = alias
+	}
+	keywords, ok := languageKeywords[language]
+	if !ok {
+		keywords = shellKeywords
+	}
+	commentPrefix := "#"
+	if language == "node" || language == "go" {
+		commentPrefix = "//"
+	}
+
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		lines[i] = highlightLine(line, keywords, commentPrefix)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func highlightLine(line string, keywords []string, commentPrefix string) string {
+	var out strings.Builder
+	var word strings.Builder
+	flushWord := func() {
+		if word.Len() == 0 {
+			return
+		}
+		if slices.Contains(keywords, word.String()) {
+			out.WriteString(paint(Stdout, theme.Keyword, word.String()))
+		} else {
+			out.WriteString(word.String())
+		}
+		word.Reset()
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], commentPrefix) && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			flushWord()
+			out.WriteString(paint(Stdout, theme.Comment, line[i:]))
+			return out.String()
+		case c == '"' || c == '\'' || c == '`':
+			flushWord()
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				// single quotes have no escapes in shell
+				if line[end] == '\\' && c != '\'' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(line))
+			out.WriteString(paint(Stdout, theme.String, line[i:end]))
+			i = end - 1
+		case c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9':
+			word.WriteByte(c)
+		default:
+			flushWord()
+			out.WriteByte(c)
+		}
+	}
+	flushWord()
+	return out.String()
+}
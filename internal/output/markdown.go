@@ -0,0 +1,70 @@
+package output
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRegex    = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	listItemRegex   = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	inlineCodeRegex = regexp.MustCompile("`([^`]+)`")
+	boldRegex       = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+)
+
+// Markdown renders the markdown the backend uses in explanations for the terminal:
+// headings, lists, bold text, inline code and fenced code blocks
+func Markdown(text string) string {
+	var out []string
+	var block []string
+	blockLanguage := ""
+	inBlock := false
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			if inBlock {
+				code := Highlight(strings.Join(block, "\n"), blockLanguage)
+				for _, codeLine := range strings.Split(code, "\n") {
+					out = append(out, "    "+codeLine)
+				}
+				block = nil
+			} else {
+				blockLanguage = strings.TrimPrefix(trimmed, "```")
+			}
+			inBlock = !inBlock
+			continue
+		}
+		if inBlock {
+			block = append(block, line)
+			continue
+		}
+
+		if match := headingRegex.FindStringSubmatch(line); match != nil {
+			out = append(out, paint(Stdout, theme.Heading, match[2]))
+			continue
+		}
+		if match := listItemRegex.FindStringSubmatch(line); match != nil {
+			line = match[1] + "  • " + match[2]
+		}
+		out = append(out, renderInline(line))
+	}
+
+	// an unclosed block is still code
+	for _, line := range block {
+		out = append(out, "    "+line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func renderInline(line string) string {
+	if !ColorEnabled(Stdout) {
+		return line
+	}
+	line = inlineCodeRegex.ReplaceAllStringFunc(line, func(code string) string {
+		return paint(Stdout, theme.Code, code[1:len(code)-1])
+	})
+	return boldRegex.ReplaceAllStringFunc(line, func(bold string) string {
+		return paint(Stdout, theme.Bold, bold[2:len(bold)-2])
+	})
+}
@@ -0,0 +1,102 @@
+// Package output writes everything idk shows to the user. Results and questions go to stdout,
+// errors and warnings to stderr, and colors are only used on terminals that want them.
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+var (
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
+
+// separator frames boxes and code blocks
+const separator = "-----------------------------------"
+
+// Println writes a line to stdout
+func Println(a ...any) {
+	fmt.Fprintln(Stdout, a...)
+}
+
+// Printf writes to stdout
+func Printf(format string, a ...any) {
+	fmt.Fprintf(Stdout, format, a...)
+}
+
+// Infoln writes a progress line to stderr, for notes that must not mix with a result on stdout
+func Infoln(a ...any) {
+	fmt.Fprintln(Stderr, paint(Stderr, theme.Muted, fmt.Sprint(a...)))
+}
+
+// Errorln writes an error line to stderr
+func Errorln(a ...any) {
+	fmt.Fprintln(Stderr, paint(Stderr, theme.Error, fmt.Sprint(a...)))
+}
+
+// Warnln writes a warning line to stderr
+func Warnln(a ...any) {
+	fmt.Fprintln(Stderr, paint(Stderr, theme.Warning, fmt.Sprint(a...)))
+}
+
+// Box writes lines to stdout between two separators
+func Box(lines ...string) {
+	Println(paint(Stdout, theme.Muted, separator))
+	for _, line := range lines {
+		Println(line)
+	}
+	Println(paint(Stdout, theme.Muted, separator))
+}
+
+// Code writes a command or script to stdout between two separators, highlighted for language
+func Code(code string, language string) {
+	Box(Highlight(code, language))
+}
+
+// Risk returns the risk level colored by how dangerous it is
+func Risk(risk string) string {
+	switch risk {
+	case "high":
+		return paint(Stdout, theme.Error, risk)
+	case "medium":
+		return paint(Stdout, theme.Warning, risk)
+	default:
+		return paint(Stdout, theme.Success, risk)
+	}
+}
+
+// Status returns the result of a check, pass, warn or fail, colored like a risk level
+func Status(status string) string {
+	switch status {
+	case "fail":
+		return paint(Stdout, theme.Error, status)
+	case "warn":
+		return paint(Stdout, theme.Warning, status)
+	default:
+		return paint(Stdout, theme.Success, status)
+	}
+}
+
+// ColorEnabled checks if w is a terminal that accepts colors. NO_COLOR and TERM=dumb turn colors off everywhere.
+func ColorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	file, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(file.Fd()))
+}
+
+// paint wraps text in the SGR escape sequence style, if w shows colors
+func paint(w io.Writer, style string, text string) string {
+	if style == "" || text == "" || !ColorEnabled(w) {
+		return text
+	}
+	// reapply the style after line breaks so pagers and panes that split lines keep it
+	text = strings.ReplaceAll(text, "\n", "\x1b[0m\n\x1b["+style+"m")
+	return "\x1b[" + style + "m" + text + "\x1b[0m"
+}
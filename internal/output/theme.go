@@ -0,0 +1,77 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	ThemeDark  = "dark"
+	ThemeLight = "light"
+	ThemePlain = "plain"
+)
+
+// Theme holds the SGR parameters used for each kind of text, e.g. "1;34" for bold blue
+type Theme struct {
+	Keyword string
+	String  string
+	Comment string
+	Heading string
+	Code    string
+	Bold    string
+	Muted   string
+	Error   string
+	Warning string
+	Success string
+}
+
+var themes = map[string]Theme{
+	ThemeDark: {
+		Keyword: "95",
+		String:  "92",
+		Comment: "90;3",
+		Heading: "1;94",
+		Code:    "96",
+		Bold:    "1",
+		Muted:   "90",
+		Error:   "91",
+		Warning: "93",
+		Success: "92",
+	},
+	ThemeLight: {
+		Keyword: "35",
+		String:  "32",
+		Comment: "90;3",
+		Heading: "1;34",
+		Code:    "36",
+		Bold:    "1",
+		Muted:   "90",
+		Error:   "31",
+		Warning: "33",
+		Success: "32",
+	},
+	ThemePlain: {},
+}
+
+var theme = themes[ThemeDark]
+
+// SetTheme switches to one of the built-in themes
+func SetTheme(name string) error {
+	selected, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme `%s`. Use %s", name, strings.Join(ThemeNames(), ", "))
+	}
+	theme = selected
+	return nil
+}
+
+// ThemeNames lists the built-in themes
+func ThemeNames() []string {
+	var names []string
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
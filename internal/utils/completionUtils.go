@@ -0,0 +1,340 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Completion kinds set with the `complete` struct tag next to go-arg's `arg` tag. Any other
+// value is a comma separated list of fixed values, e.g. `complete:"text,json"`.
+const (
+	CompleteFile = "file"
+	// CompletePrompt, CompleteScript and CompleteSnapshot are looked up when completing,
+	// by calling `idk __complete <kind> <words>`
+	CompletePrompt   = "prompt"
+	CompleteScript   = "script"
+	CompleteSnapshot = "snapshot"
+)
+
+// CompletionCommand describes a command and its subcommands for shell completion
+type CompletionCommand struct {
+	Name        string
+	Help        string
+	Flags       []CompletionFlag
+	Subcommands []CompletionCommand
+	// Positional is what positional arguments complete, empty if there is nothing to complete
+	Positional string
+}
+
+type CompletionFlag struct {
+	Name       string
+	Help       string
+	TakesValue bool
+	Complete   string
+}
+
+// CompletionCommandFromArgs reads the flags, positionals and subcommands of a go-arg struct
+func CompletionCommandFromArgs(name string, help string, args any) CompletionCommand {
+	command := CompletionCommand{Name: name, Help: help}
+
+	argsType := reflect.TypeOf(args)
+	for argsType.Kind() == reflect.Pointer {
+		argsType = argsType.Elem()
+	}
+	for i := 0; i < argsType.NumField(); i++ {
+		field := argsType.Field(i)
+		tag := field.Tag.Get("arg")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		flag := CompletionFlag{
+			Help:       field.Tag.Get("help"),
+			TakesValue: field.Type.Kind() != reflect.Bool,
+			Complete:   field.Tag.Get("complete"),
+		}
+		positional := false
+		for _, part := range strings.Split(tag, ",") {
+			switch {
+			case part == "positional":
+				positional = true
+			case strings.HasPrefix(part, "subcommand:"):
+				subcommand := CompletionCommandFromArgs(strings.TrimPrefix(part, "subcommand:"), flag.Help, reflect.New(field.Type.Elem()).Interface())
+				command.Subcommands = append(command.Subcommands, subcommand)
+				positional = true
+			case strings.HasPrefix(part, "--"):
+				flag.Name = part
+			}
+		}
+
+		if positional {
+			if flag.Complete != "" {
+				command.Positional = flag.Complete
+			}
+			continue
+		}
+		if flag.Name == "" {
+			flag.Name = "--" + kebabCase(field.Name)
+		}
+		command.Flags = append(command.Flags, flag)
+	}
+
+	command.Flags = append(command.Flags, CompletionFlag{Name: "--help", Help: "display this help and exit"})
+	sort.Slice(command.Flags, func(i, j int) bool {
+		return command.Flags[i].Name < command.Flags[j].Name
+	})
+	return command
+}
+
+// kebabCase turns a field name like PrintOnly into print-only, the way go-arg names flags
+func kebabCase(name string) string {
+	var out strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				out.WriteByte('-')
+			}
+			r += 'a' - 'A'
+		}
+		out.WriteRune(r)
+	}
+	return out.String()
+}
+
+func (c CompletionCommand) flagNames() []string {
+	var names []string
+	for _, flag := range c.Flags {
+		names = append(names, flag.Name)
+	}
+	return names
+}
+
+func (c CompletionCommand) subcommandNames() []string {
+	var names []string
+	for _, subcommand := range c.Subcommands {
+		names = append(names, subcommand.Name)
+	}
+	return names
+}
+
+func functionName(program string, path []string) string {
+	return "__" + strings.ReplaceAll(strings.Join(append([]string{program}, path...), "_"), "-", "_")
+}
+
+// fixedValues returns the values of a completion that is a list of fixed values
+func fixedValues(complete string) []string {
+	switch complete {
+	case "", CompleteFile, CompletePrompt, CompleteScript, CompleteSnapshot:
+		return nil
+	}
+	return strings.Split(complete, ",")
+}
+
+// BashCompletion generates a bash completion script for the program
+func BashCompletion(program string, root CompletionCommand) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# bash completion for %s, load it with: source <(%s completion bash)\n\n", program, program)
+	writeBashFunction(&out, program, nil, root)
+	fmt.Fprintf(&out, "_%s() {\n\tCOMPREPLY=()\n\t%s 1\n}\n\ncomplete -F _%s %s\n", program, functionName(program, nil), program, program)
+	return out.String()
+}
+
+func writeBashFunction(out *strings.Builder, program string, path []string, command CompletionCommand) {
+	for _, subcommand := range command.Subcommands {
+		writeBashFunction(out, program, append(append([]string{}, path...), subcommand.Name), subcommand)
+	}
+
+	// $1 is the index of the first word after the command
+	fmt.Fprintf(out, "%s() {\n", functionName(program, path))
+	out.WriteString("\tlocal i=$1 cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	if len(command.Subcommands) > 0 {
+		out.WriteString("\tif [[ $COMP_CWORD -gt $i ]]; then\n\t\tcase \"${COMP_WORDS[$i]}\" in\n")
+		for _, subcommand := range command.Subcommands {
+			fmt.Fprintf(out, "\t\t\t%s) %s $((i+1)); return ;;\n", subcommand.Name, functionName(program, append(append([]string{}, path...), subcommand.Name)))
+		}
+		out.WriteString("\t\tesac\n\tfi\n")
+	}
+
+	out.WriteString("\tcase \"$prev\" in\n")
+	for _, flag := range command.Flags {
+		if !flag.TakesValue {
+			continue
+		}
+		fmt.Fprintf(out, "\t\t%s) %s; return ;;\n", flag.Name, bashCompleteValues(program, flag.Complete))
+	}
+	out.WriteString("\tesac\n")
+
+	fmt.Fprintf(out, "\tif [[ \"$cur\" == -* ]]; then\n\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n\t\treturn\n\tfi\n", strings.Join(command.flagNames(), " "))
+	if len(command.Subcommands) > 0 {
+		fmt.Fprintf(out, "\tif [[ $COMP_CWORD -eq $i ]]; then\n\t\tCOMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n\tfi\n", strings.Join(command.subcommandNames(), " "))
+	}
+	if command.Positional != "" {
+		fmt.Fprintf(out, "\t%s\n", bashCompleteValues(program, command.Positional))
+	}
+	out.WriteString("}\n\n")
+}
+
+func bashCompleteValues(program string, complete string) string {
+	switch complete {
+	case "":
+		return ":"
+	case CompleteFile:
+		return "COMPREPLY+=($(compgen -f -- \"$cur\"))"
+	case CompletePrompt, CompleteScript, CompleteSnapshot:
+		return fmt.Sprintf("local IFS=$'\\n'; COMPREPLY+=($(compgen -W \"$(%s __complete %s \"${COMP_WORDS[@]:$i:$((COMP_CWORD-i))}\" 2>/dev/null)\" -- \"$cur\"))", program, complete)
+	}
+	return fmt.Sprintf("COMPREPLY+=($(compgen -W \"%s\" -- \"$cur\"))", strings.Join(fixedValues(complete), " "))
+}
+
+// ZshCompletion generates a zsh completion script for the program
+func ZshCompletion(program string, root CompletionCommand) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "#compdef %s\n# zsh completion for %s, load it with: source <(%s completion zsh)\n\n", program, program, program)
+	writeZshFunction(&out, program, nil, root)
+	fmt.Fprintf(&out, "_%s() {\n\t%s 2\n}\n\ncompdef _%s %s\n", program, functionName(program, nil), program, program)
+	return out.String()
+}
+
+func writeZshFunction(out *strings.Builder, program string, path []string, command CompletionCommand) {
+	for _, subcommand := range command.Subcommands {
+		writeZshFunction(out, program, append(append([]string{}, path...), subcommand.Name), subcommand)
+	}
+
+	// $1 is the index in $words of the first word after the command
+	fmt.Fprintf(out, "%s() {\n\tlocal i=$1\n", functionName(program, path))
+	if len(command.Subcommands) > 0 {
+		out.WriteString("\tif (( CURRENT > i )); then\n\t\tcase $words[i] in\n")
+		for _, subcommand := range command.Subcommands {
+			fmt.Fprintf(out, "\t\t\t%s) %s $((i+1)); return ;;\n", subcommand.Name, functionName(program, append(append([]string{}, path...), subcommand.Name)))
+		}
+		out.WriteString("\t\tesac\n\tfi\n")
+	}
+
+	out.WriteString("\tcase $words[CURRENT-1] in\n")
+	for _, flag := range command.Flags {
+		if !flag.TakesValue {
+			continue
+		}
+		fmt.Fprintf(out, "\t\t%s) %s; return ;;\n", flag.Name, zshCompleteValues(program, flag.Complete))
+	}
+	out.WriteString("\tesac\n")
+
+	out.WriteString("\tif [[ $words[CURRENT] == -* ]]; then\n\t\tlocal -a flags=(\n")
+	for _, flag := range command.Flags {
+		fmt.Fprintf(out, "\t\t\t%s\n", zshQuote(flag.Name+":"+flag.Help))
+	}
+	out.WriteString("\t\t)\n\t\t_describe 'flag' flags\n\t\treturn\n\tfi\n")
+	if len(command.Subcommands) > 0 {
+		out.WriteString("\tif (( CURRENT == i )); then\n\t\tlocal -a subcommands=(\n")
+		for _, subcommand := range command.Subcommands {
+			fmt.Fprintf(out, "\t\t\t%s\n", zshQuote(subcommand.Name+":"+subcommand.Help))
+		}
+		out.WriteString("\t\t)\n\t\t_describe 'command' subcommands\n\tfi\n")
+	}
+	if command.Positional != "" {
+		fmt.Fprintf(out, "\t%s\n", zshCompleteValues(program, command.Positional))
+	}
+	out.WriteString("}\n\n")
+}
+
+func zshCompleteValues(program string, complete string) string {
+	switch complete {
+	case "":
+		return ":"
+	case CompleteFile:
+		return "_files"
+	case CompletePrompt, CompleteScript, CompleteSnapshot:
+		return fmt.Sprintf("compadd -- ${(f)\"$(%s __complete %s ${words[i,CURRENT-1]} 2>/dev/null)\"}", program, complete)
+	}
+	return "compadd -- " + strings.Join(fixedValues(complete), " ")
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// FishCompletion generates a fish completion script for the program
+func FishCompletion(program string, root CompletionCommand) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# fish completion for %s, load it with: %s completion fish | source\n\n", program, program)
+	fmt.Fprintf(&out, "complete -c %s -f\n", program)
+	writeFishCommand(&out, program, nil, root)
+	return out.String()
+}
+
+func writeFishCommand(out *strings.Builder, program string, path []string, command CompletionCommand) {
+	// a command applies once its path was typed and none of its subcommands was
+	var conditions []string
+	for _, name := range path {
+		conditions = append(conditions, "__fish_seen_subcommand_from "+name)
+	}
+	if len(command.Subcommands) > 0 {
+		conditions = append(conditions, "not __fish_seen_subcommand_from "+strings.Join(command.subcommandNames(), " "))
+	}
+	condition := ""
+	if len(conditions) > 0 {
+		condition = fmt.Sprintf(" -n '%s'", strings.Join(conditions, "; and "))
+	}
+
+	for _, flag := range command.Flags {
+		line := fmt.Sprintf("complete -c %s%s -l %s -d %s", program, condition, strings.TrimPrefix(flag.Name, "--"), fishQuote(flag.Help))
+		if flag.TakesValue {
+			line += " -r" + fishCompleteValues(program, flag.Complete)
+		}
+		out.WriteString(line + "\n")
+	}
+	for _, subcommand := range command.Subcommands {
+		fmt.Fprintf(out, "complete -c %s%s -a %s -d %s\n", program, condition, subcommand.Name, fishQuote(subcommand.Help))
+	}
+	if command.Positional != "" {
+		fmt.Fprintf(out, "complete -c %s%s%s\n", program, condition, fishCompleteValues(program, command.Positional))
+	}
+
+	for _, subcommand := range command.Subcommands {
+		writeFishCommand(out, program, append(append([]string{}, path...), subcommand.Name), subcommand)
+	}
+}
+
+func fishCompleteValues(program string, complete string) string {
+	switch complete {
+	case "":
+		return ""
+	case CompleteFile:
+		return " -F"
+	case CompletePrompt, CompleteScript, CompleteSnapshot:
+		return fmt.Sprintf(" -a '(%s __complete %s (commandline -opc)[2..-1] 2>/dev/null)'", program, complete)
+	}
+	return fmt.Sprintf(" -a %s", fishQuote(strings.Join(fixedValues(complete), " ")))
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
+
+// PromptCompletions returns the word that followed typed in previous prompts, most recent prompts first.
+// prompts must be ordered oldest first, like LoadHistory returns them.
+func PromptCompletions(prompts []string, typed []string) []string {
+	var words []string
+	seen := map[string]bool{}
+	for i := len(prompts) - 1; i >= 0; i-- {
+		promptWords := strings.Fields(prompts[i])
+		if len(promptWords) <= len(typed) {
+			continue
+		}
+		matches := true
+		for j, word := range typed {
+			if !strings.EqualFold(promptWords[j], word) {
+				matches = false
+				break
+			}
+		}
+		next := promptWords[len(typed)]
+		if matches && !seen[next] {
+			seen[next] = true
+			words = append(words, next)
+		}
+	}
+	return words
+}
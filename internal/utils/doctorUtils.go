@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ClipboardTool returns the program --copy uses to reach the clipboard. atotto/clipboard
+// needs one of xclip, xsel or wl-copy on Linux, other platforms have a clipboard built in.
+func ClipboardTool() (string, error) {
+	var candidates []string
+	switch runtime.GOOS {
+	case "linux":
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, "wl-copy")
+		}
+		candidates = append(candidates, "xclip", "xsel", "termux-clipboard-set")
+	case "darwin":
+		candidates = []string{"pbcopy"}
+	default:
+		return "built-in", nil
+	}
+
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("none of %s found", strings.Join(candidates, ", "))
+}
+
+// IsPortFree checks if a local port can be listened on
+func IsPortFree(port int) error {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return err
+	}
+	return listener.Close()
+}
+
+// DetectShell returns the name and path of the shell of the user, or empty strings if it is unknown
+func DetectShell() (string, string) {
+	if runtime.GOOS == "windows" {
+		if os.Getenv("PSModulePath") != "" {
+			return "powershell", ""
+		}
+		return "cmd", os.Getenv("ComSpec")
+	}
+	path := os.Getenv("SHELL")
+	if path == "" {
+		return "", ""
+	}
+	return filepath.Base(path), path
+}
+
+// CheckWritableDir checks that files can be created in dir, creating dir if it doesn't exist
+func CheckWritableDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".idk-doctor-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
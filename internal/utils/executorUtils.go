@@ -0,0 +1,18 @@
+package utils
+
+// Executor runs generated commands and scripts
+type Executor interface {
+	RunCommand(commandStr string) error
+	RunScript(script string, language ScriptLanguage, options ScriptRunOptions) error
+}
+
+// HostExecutor runs commands and scripts directly on this machine
+type HostExecutor struct{}
+
+func (HostExecutor) RunCommand(commandStr string) error {
+	return RunCommand(commandStr)
+}
+
+func (HostExecutor) RunScript(script string, language ScriptLanguage, options ScriptRunOptions) error {
+	return RunScript(script, language, options)
+}
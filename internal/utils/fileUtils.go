@@ -9,7 +9,8 @@ import (
 	"strings"
 )
 
-func BackupFile(filePath string) error {
+// BackupFile copies filePath to backupFilePath, keeping its permissions
+func BackupFile(filePath string, backupFilePath string) error {
 	// Open the original file
 	originalFile, err := os.Open(filePath)
 	if err != nil {
@@ -17,11 +18,13 @@ func BackupFile(filePath string) error {
 	}
 	defer originalFile.Close()
 
-	// Create a backup file path by appending .idk.backup to the original file path
-	backupFilePath := filePath + ".idk.backup"
+	info, err := originalFile.Stat()
+	if err != nil {
+		return err
+	}
 
 	// Create/open the backup file
-	backupFile, err := os.Create(backupFilePath)
+	backupFile, err := os.OpenFile(backupFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
 	if err != nil {
 		return err
 	}
@@ -39,7 +42,7 @@ func BackupFile(filePath string) error {
 		return err
 	}
 
-	return nil
+	return os.Chmod(backupFilePath, info.Mode().Perm())
 }
 
 func GetAbsoluteHomeDirectoryPath(paths []string) string {
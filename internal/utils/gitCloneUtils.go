@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// scpLikeGitUrl matches the short ssh form of git urls, like git@github.com:org/repo.git
+var scpLikeGitUrl = regexp.MustCompile(`^[\w.-]+@[\w.-]+:[^/\\].*$`)
+
+// IsGitUrl checks if arg names a git repository: a remote url, a file:// url or a local repository,
+// which may be bare
+func IsGitUrl(arg string) bool {
+	for _, scheme := range []string{"https://", "http://", "ssh://", "git://", "file://"} {
+		if strings.HasPrefix(arg, scheme) {
+			return true
+		}
+	}
+	if scpLikeGitUrl.MatchString(arg) {
+		return true
+	}
+	return isLocalGitRepository(arg)
+}
+
+// IsLocalGitUrl checks if url is a file:// url or a local path rather than a remote repository
+func IsLocalGitUrl(url string) bool {
+	return strings.HasPrefix(url, "file://") || isLocalGitRepository(url)
+}
+
+func isLocalGitRepository(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+		return true
+	}
+	// bare repositories keep HEAD and objects at the top
+	_, headErr := os.Stat(filepath.Join(path, "HEAD"))
+	_, objectsErr := os.Stat(filepath.Join(path, "objects"))
+	return headErr == nil && objectsErr == nil
+}
+
+// CloneDirName returns the directory `git clone` would create for url, like repo for git@host:org/repo.git
+func CloneDirName(url string) string {
+	name := strings.TrimRight(url, "/\\")
+	name = strings.TrimSuffix(name, "/.git")
+	if index := strings.LastIndexAny(name, "/\\:"); index >= 0 {
+		name = name[index+1:]
+	}
+	name = strings.TrimSuffix(name, ".git")
+	if name == "" || name == "." || name == ".." {
+		return "repository"
+	}
+	return name
+}
+
+// CloneRepository clones url into dir, which must not exist or be empty, and checks out ref if it is set.
+// ref can be a branch, a tag or a commit.
+func CloneRepository(url string, dir string, ref string) error {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s already exists and is not empty", dir)
+	}
+
+	if err := runGit("", "clone", "--", url, dir); err != nil {
+		return fmt.Errorf("git clone failed: %w", err)
+	}
+	if ref == "" {
+		return nil
+	}
+	if err := runGit(dir, "-c", "advice.detachedHead=false", "checkout", ref, "--"); err != nil {
+		return fmt.Errorf("could not check out %s: %w", ref, err)
+	}
+	return nil
+}
+
+// HasSubmodules checks if the repository in dir declares submodules
+func HasSubmodules(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".gitmodules"))
+	return err == nil
+}
+
+// UpdateSubmodules checks out the submodules of the repository in dir at the commits it records.
+// Git refuses submodules with local urls unless allowLocal is set, which is only safe for repositories
+// that are themselves local.
+func UpdateSubmodules(dir string, allowLocal bool) error {
+	args := []string{"submodule", "update", "--init", "--recursive"}
+	if allowLocal {
+		args = append([]string{"-c", "protocol.file.allow=always"}, args...)
+	}
+	if err := runGit(dir, args...); err != nil {
+		return fmt.Errorf("git submodule update failed: %w", err)
+	}
+	return nil
+}
+
+// UsesGitLfs checks if any .gitattributes file of the repository in dir stores files in Git LFS
+func UsesGitLfs(dir string) bool {
+	for _, file := range strings.Split(gitOutput(dir, "ls-files"), "\n") {
+		if filepath.Base(file) != ".gitattributes" {
+			continue
+		}
+		attributes, err := os.Open(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(attributes)
+		for scanner.Scan() {
+			if strings.Contains(scanner.Text(), "filter=lfs") {
+				attributes.Close()
+				return true
+			}
+		}
+		attributes.Close()
+	}
+	return false
+}
+
+// IsGitLfsInstalled checks if the git lfs extension is available
+func IsGitLfsInstalled() bool {
+	return exec.Command("git", "lfs", "version").Run() == nil
+}
+
+// PullGitLfs downloads the LFS files of the checked out commit of the repository in dir
+func PullGitLfs(dir string) error {
+	if err := runGit(dir, "lfs", "pull"); err != nil {
+		return fmt.Errorf("git lfs pull failed: %w", err)
+	}
+	return nil
+}
+
+// runGit runs git in dir, showing its progress on stderr so stdout keeps only the result of idk
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
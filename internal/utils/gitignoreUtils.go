@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// GitIgnore holds the patterns of one or more .gitignore files
+type GitIgnore struct {
+	patterns []gitIgnorePattern
+}
+
+type gitIgnorePattern struct {
+	base     string // directory of the .gitignore file, relative to the root ("" for the root)
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// LoadGitIgnore reads the .gitignore file in rootDir. A missing file results in an empty matcher.
+func LoadGitIgnore(rootDir string) *GitIgnore {
+	g := &GitIgnore{}
+	g.AddFile(rootDir, "")
+	return g
+}
+
+// AddFile adds the patterns of the .gitignore file in rootDir/relDir, scoped to relDir
+func (g *GitIgnore) AddFile(rootDir string, relDir string) {
+	file, err := os.Open(filepath.Join(rootDir, relDir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p := gitIgnorePattern{base: filepath.ToSlash(relDir)}
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		// a slash anywhere but at the end anchors the pattern to the .gitignore directory
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		p.pattern = line
+		g.patterns = append(g.patterns, p)
+	}
+}
+
+// IsIgnored reports whether relPath (relative to the root, slash or OS separated) is ignored.
+// The .git directory is always ignored.
+func (g *GitIgnore) IsIgnored(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	if relPath == ".git" || strings.HasPrefix(relPath, ".git/") {
+		return true
+	}
+
+	ignored := false
+	// later patterns take precedence over earlier ones, so the last match wins
+	for _, p := range g.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.matches(relPath) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func (p gitIgnorePattern) matches(relPath string) bool {
+	if p.base != "" {
+		if !strings.HasPrefix(relPath, p.base+"/") {
+			return false
+		}
+		relPath = strings.TrimPrefix(relPath, p.base+"/")
+	}
+
+	if !p.anchored {
+		return matchGlob(p.pattern, path.Base(relPath))
+	}
+	return matchGlob(p.pattern, relPath)
+}
+
+// matchGlob matches a slash separated path against a gitignore glob, supporting `**` segments
+func matchGlob(pattern string, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		matched, err := path.Match(pattern, name)
+		return err == nil && matched
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	nameParts := strings.Split(name, "/")
+	return matchGlobParts(patternParts, nameParts)
+}
+
+func matchGlobParts(patternParts []string, nameParts []string) bool {
+	for len(patternParts) > 0 {
+		if patternParts[0] == "**" {
+			// `**` matches zero or more directories
+			for i := 0; i <= len(nameParts); i++ {
+				if matchGlobParts(patternParts[1:], nameParts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(nameParts) == 0 {
+			return false
+		}
+		matched, err := path.Match(patternParts[0], nameParts[0])
+		if err != nil || !matched {
+			return false
+		}
+		patternParts = patternParts[1:]
+		nameParts = nameParts[1:]
+	}
+	return len(nameParts) == 0
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// HistoryEntry records one command or script that idk executed
+type HistoryEntry struct {
+	Id         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Prompt     string    `json:"prompt"`
+	ActionType string    `json:"actionType"`
+	Command    string    `json:"command"`
+	// Language is the name of the ScriptLanguage the command ran with, entries from older versions have none
+	Language   string `json:"language,omitempty"`
+	Dir        string `json:"dir"`
+	ExitCode   int    `json:"exitCode"`
+	SnapshotId string `json:"snapshotId,omitempty"`
+}
+
+var historyIdRegex = regexp.MustCompile(`^\d{8}-\d{6}-[a-zA-Z0-9]{4}$`)
+
+// NewHistoryId returns a sortable id like 20240102-150405-a1B2
+func NewHistoryId() string {
+	return fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), GenerateRandomString(4))
+}
+
+// IsHistoryId checks if s looks like an id returned by NewHistoryId
+func IsHistoryId(s string) bool {
+	return historyIdRegex.MatchString(s)
+}
+
+func historyFilePath() string {
+	return GetAbsoluteHomeDirectoryPath([]string{".idk", "history.jsonl"})
+}
+
+// AppendHistory adds entry to the end of the history file
+func AppendHistory(entry HistoryEntry) error {
+	historyPath := historyFilePath()
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0700); err != nil {
+		return err
+	}
+
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(bytes, '\n'))
+	return err
+}
+
+// LoadHistory reads all history entries, oldest first. Unreadable lines are skipped.
+func LoadHistory() ([]HistoryEntry, error) {
+	file, err := os.Open(historyFilePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
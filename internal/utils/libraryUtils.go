@@ -0,0 +1,296 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LibraryScript is the metadata of a script saved in the script library
+type LibraryScript struct {
+	Name        string        `json:"name"`
+	Description string        `json:"description,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	Language    string        `json:"language"`
+	Params      []ScriptParam `json:"params,omitempty"`
+	CreatedAt   time.Time     `json:"createdAt"`
+}
+
+// ScriptParam is a parameter a library script declares. Values are passed to the script as
+// environment variables named after the parameter.
+type ScriptParam struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+var (
+	libraryNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.\-]*$`)
+	paramNameRegex   = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// reservedParamNames are environment variables a parameter must not replace, a shared script could
+// otherwise point PATH or the dynamic loader somewhere else
+var reservedParamNames = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "PWD", "OLDPWD", "IFS", "ENV", "BASH_ENV", "CDPATH",
+	"TMPDIR", "TERM", "LANG", "PS4", "PYTHONPATH", "PYTHONSTARTUP", "NODE_OPTIONS", "NODE_PATH",
+	"GOFLAGS", "RUBYOPT", "PERL5OPT", "SSH_AUTH_SOCK",
+}
+
+// reservedParamPrefixes are prefixes of environment variables that change how programs run
+var reservedParamPrefixes = []string{"LD_", "DYLD_", "LC_", "GIT_", "IDK_"}
+
+// ErrLibraryScriptExists is returned by SaveLibraryScript when a script with the same name is saved already
+var ErrLibraryScriptExists = errors.New("a script with this name already exists")
+
+// DefaultScriptLibraryDir is where scripts are saved unless scriptLibraryDir is configured
+func DefaultScriptLibraryDir() string {
+	return GetAbsoluteHomeDirectoryPath([]string{".idk", "scripts"})
+}
+
+// ValidateLibraryScript checks the name and parameters of a script before it is saved
+func ValidateLibraryScript(meta LibraryScript) error {
+	if !libraryNameRegex.MatchString(meta.Name) {
+		return fmt.Errorf("invalid script name `%s`. Use letters, numbers, `.`, `-` and `_`", meta.Name)
+	}
+	for _, param := range meta.Params {
+		if !paramNameRegex.MatchString(param.Name) {
+			return fmt.Errorf("invalid parameter name `%s`. Use letters, numbers and `_`", param.Name)
+		}
+		if isReservedParamName(param.Name) {
+			return fmt.Errorf("invalid parameter name `%s`, it would replace an environment variable scripts rely on", param.Name)
+		}
+	}
+	return nil
+}
+
+func isReservedParamName(name string) bool {
+	name = strings.ToUpper(name)
+	if slices.Contains(reservedParamNames, name) {
+		return true
+	}
+	for _, prefix := range reservedParamPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveLibraryScript stores the script in libraryDir/<name>/. A script with the same name is only
+// replaced if overwrite is set, otherwise ErrLibraryScriptExists is returned.
+func SaveLibraryScript(libraryDir string, meta LibraryScript, script string, overwrite bool) error {
+	if err := ValidateLibraryScript(meta); err != nil {
+		return err
+	}
+	language := DetectScriptLanguage(meta.Language, script)
+	meta.Language = language.Name
+
+	scriptDir := filepath.Join(libraryDir, meta.Name)
+	if _, err := os.Stat(scriptDir); err == nil && !overwrite {
+		return ErrLibraryScriptExists
+	}
+	if err := os.RemoveAll(scriptDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(scriptDir, 0755); err != nil {
+		return err
+	}
+
+	metaBytes, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(scriptDir, "meta.json"), metaBytes, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(scriptDir, "script"+language.Extension), []byte(WithShebang(script, language)), 0755)
+}
+
+// LoadLibraryScript returns the metadata and the content of a saved script
+func LoadLibraryScript(libraryDir string, name string) (*LibraryScript, string, error) {
+	if !libraryNameRegex.MatchString(name) {
+		return nil, "", fmt.Errorf("invalid script name `%s`", name)
+	}
+
+	scriptDir := filepath.Join(libraryDir, name)
+	metaBytes, err := os.ReadFile(filepath.Join(scriptDir, "meta.json"))
+	if err != nil {
+		return nil, "", err
+	}
+	var meta LibraryScript
+	if err := json.Unmarshal(metaBytes, &meta); err != nil {
+		return nil, "", err
+	}
+
+	language := DetectScriptLanguage(meta.Language, "")
+	scriptBytes, err := os.ReadFile(filepath.Join(scriptDir, "script"+language.Extension))
+	if err != nil {
+		return nil, "", err
+	}
+	return &meta, string(scriptBytes), nil
+}
+
+// ListLibraryScripts returns every script in the library sorted by name, optionally only the ones tagged with tag
+func ListLibraryScripts(libraryDir string, tag string) ([]LibraryScript, error) {
+	entries, err := os.ReadDir(libraryDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var scripts []LibraryScript
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		meta, _, err := LoadLibraryScript(libraryDir, entry.Name())
+		if err != nil {
+			continue
+		}
+		if tag != "" && !slices.Contains(meta.Tags, tag) {
+			continue
+		}
+		scripts = append(scripts, *meta)
+	}
+
+	sort.Slice(scripts, func(i, j int) bool {
+		return scripts[i].Name < scripts[j].Name
+	})
+	return scripts, nil
+}
+
+// RemoveLibraryScript deletes a saved script
+func RemoveLibraryScript(libraryDir string, name string) error {
+	if _, _, err := LoadLibraryScript(libraryDir, name); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(libraryDir, name))
+}
+
+// ScriptParamEnv turns `key=value` arguments into the environment of a library script,
+// applying defaults and rejecting unknown or missing required parameters
+func ScriptParamEnv(meta LibraryScript, paramArgs []string) ([]string, error) {
+	values := map[string]string{}
+	for _, paramArg := range paramArgs {
+		key, value, ok := strings.Cut(paramArg, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid parameter `%s`, expected key=value", paramArg)
+		}
+		values[key] = value
+	}
+
+	var env []string
+	for _, param := range meta.Params {
+		value, ok := values[param.Name]
+		delete(values, param.Name)
+		if !ok {
+			if param.Required {
+				return nil, fmt.Errorf("missing required parameter `%s`", param.Name)
+			}
+			value = param.Default
+		}
+		env = append(env, fmt.Sprintf("%s=%s", param.Name, value))
+	}
+
+	for key := range values {
+		return nil, fmt.Errorf("unknown parameter `%s`", key)
+	}
+	return env, nil
+}
+
+// ParseScriptParams parses declarations like `host, port=8080` into parameters. Parameters
+// without a default are required.
+func ParseScriptParams(declarations string) []ScriptParam {
+	var params []ScriptParam
+	for _, declaration := range strings.Split(declarations, ",") {
+		declaration = strings.TrimSpace(declaration)
+		if declaration == "" {
+			continue
+		}
+		name, defaultValue, hasDefault := strings.Cut(declaration, "=")
+		params = append(params, ScriptParam{
+			Name:     strings.TrimSpace(name),
+			Default:  strings.TrimSpace(defaultValue),
+			Required: !hasDefault,
+		})
+	}
+	return params
+}
+
+// PullScriptLibrary commits the local changes of a library that is a git checkout and pulls the team's changes
+func PullScriptLibrary(libraryDir string) error {
+	if !IsGitRepository(libraryDir) {
+		return fmt.Errorf("%s is not a git repository. Share it with `idk scripts clone <git-url>` first", libraryDir)
+	}
+
+	steps := [][]string{
+		{"add", "-A"},
+		{"commit", "-m", "Update idk scripts"},
+	}
+	// a freshly created shared library has nothing to pull yet
+	if gitOutput(libraryDir, "ls-remote", "--heads", "origin") != "" {
+		steps = append(steps, []string{"pull", "--rebase"})
+	}
+	return runLibraryGitSteps(libraryDir, steps)
+}
+
+// OutgoingLibraryChanges lists the files changed by the commits of a library that the remote doesn't have yet
+func OutgoingLibraryChanges(libraryDir string) []string {
+	base := "@{upstream}"
+	if gitOutput(libraryDir, "rev-parse", "--verify", "--quiet", base) == "" {
+		// nothing was pushed yet, every commit is outgoing
+		base = gitOutput(libraryDir, "hash-object", "-t", "tree", "/dev/null")
+	}
+	changes := gitOutput(libraryDir, "diff", "--name-status", base, "HEAD")
+	if changes == "" {
+		return nil
+	}
+	return strings.Split(changes, "\n")
+}
+
+// PushScriptLibrary pushes the committed changes of a library to its remote
+func PushScriptLibrary(libraryDir string) error {
+	return runLibraryGitSteps(libraryDir, [][]string{{"push", "-u", "origin", "HEAD"}})
+}
+
+func runLibraryGitSteps(libraryDir string, steps [][]string) error {
+	for _, step := range steps {
+		cmd := exec.Command("git", step...)
+		cmd.Dir = libraryDir
+		output, err := cmd.CombinedOutput()
+		// an empty commit just means there were no local changes
+		if err != nil && !(step[0] == "commit" && strings.Contains(string(output), "nothing to commit")) {
+			return fmt.Errorf("git %s failed: %s", step[0], strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// CloneScriptLibrary clones a shared script library into libraryDir, which must not contain scripts yet
+func CloneScriptLibrary(gitUrl string, libraryDir string) error {
+	entries, err := os.ReadDir(libraryDir)
+	if err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s already contains scripts. Move them away or configure another scriptLibraryDir", libraryDir)
+	}
+	if err := os.MkdirAll(filepath.Dir(libraryDir), 0755); err != nil {
+		return err
+	}
+	os.Remove(libraryDir)
+
+	cmd := exec.Command("git", "clone", gitUrl, libraryDir)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// PackageManager is a package or version manager that setup commands can use
+type PackageManager struct {
+	Name string
+	// Commands are the executables of the manager
+	Commands []string
+	// System managers install packages for the whole machine, the others install tools next to them
+	System bool
+	// Install is the command that installs the manager, empty if idk can't install it
+	Install string
+}
+
+// packageManagers are known managers in order of preference, the first system manager found is the default
+var packageManagers = []PackageManager{
+	{Name: "brew", Commands: []string{"brew"}, System: true, Install: `/bin/bash -c "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)"`},
+	{Name: "apt", Commands: []string{"apt-get", "apt"}, System: true},
+	{Name: "dnf", Commands: []string{"dnf", "yum"}, System: true},
+	{Name: "pacman", Commands: []string{"pacman"}, System: true},
+	{Name: "apk", Commands: []string{"apk"}, System: true},
+	{Name: "nix", Commands: []string{"nix", "nix-env", "nix-shell"}, Install: "curl -L https://nixos.org/nix/install | sh -s -- --daemon"},
+	{Name: "mise", Commands: []string{"mise"}, Install: "curl https://mise.run | sh"},
+	{Name: "asdf", Commands: []string{"asdf"}},
+}
+
+// DetectPackageManagers returns the managers installed on this machine. On Linux brew is only
+// preferred if no distribution manager is installed, on macOS it is preferred over everything.
+func DetectPackageManagers() []PackageManager {
+	var found []PackageManager
+	for _, manager := range packageManagers {
+		if IsPackageManagerInstalled(manager) {
+			found = append(found, manager)
+		}
+	}
+	if runtime.GOOS == "linux" && len(found) > 1 && found[0].Name == "brew" && found[1].System {
+		found[0], found[1] = found[1], found[0]
+	}
+	return found
+}
+
+// DefaultPackageManager returns the first system manager in managers, or an empty string
+func DefaultPackageManager(managers []PackageManager) string {
+	for _, manager := range managers {
+		if manager.System {
+			return manager.Name
+		}
+	}
+	return ""
+}
+
+// PackageManagerNames returns the names of managers
+func PackageManagerNames(managers []PackageManager) []string {
+	names := []string{}
+	for _, manager := range managers {
+		names = append(names, manager.Name)
+	}
+	return names
+}
+
+// RequiredPackageManager returns the manager a shell command runs, like apt for `sudo apt-get install -y git`,
+// or nil if it doesn't run a known manager
+func RequiredPackageManager(command string) *PackageManager {
+	commands, _ := SplitShellCommands(command)
+	for _, words := range commands {
+		for len(words) > 0 && (words[0] == "sudo" || words[0] == "doas" || words[0] == "env" || strings.Contains(words[0], "=")) {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			continue
+		}
+		executable := filepath.Base(words[0])
+		for i, manager := range packageManagers {
+			for _, managerCommand := range manager.Commands {
+				if executable == managerCommand {
+					return &packageManagers[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// IsPackageManagerInstalled checks if any executable of manager is on the PATH
+func IsPackageManagerInstalled(manager PackageManager) bool {
+	for _, command := range manager.Commands {
+		if _, err := exec.LookPath(command); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// InstallPackageManager runs the installer of manager
+func InstallPackageManager(manager PackageManager) error {
+	if manager.Install == "" {
+		return fmt.Errorf("idk can't install %s, please install it manually", manager.Name)
+	}
+	return RunCommand(manager.Install)
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// useOwnProcessGroup starts cmd in a new process group so that it can be signalled as a whole.
+// When stdin is a terminal the group is also made the terminal's foreground group, so Ctrl-C
+// and reads from the terminal reach the script like in a shell. The returned function gives
+// the terminal back to idk and must be called after the process exited.
+func useOwnProcessGroup(cmd *exec.Cmd) func() {
+	stdinFd := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdinFd) {
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		return func() {}
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Foreground: true,
+		Ctty:       stdinFd,
+	}
+	return func() {
+		// idk is a background group now, taking the terminal back raises SIGTTOU unless ignored
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+		_ = unix.IoctlSetPointerInt(stdinFd, unix.TIOCSPGRP, syscall.Getpgrp())
+	}
+}
+
+// signalProcessGroup sends sig to every process in the group started by useOwnProcessGroup
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
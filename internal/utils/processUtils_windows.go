@@ -0,0 +1,18 @@
+//go:build windows
+
+package utils
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// useOwnProcessGroup is a no-op on windows, where process groups are not supported
+func useOwnProcessGroup(cmd *exec.Cmd) func() {
+	return func() {}
+}
+
+// signalProcessGroup can only kill the process itself on windows
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return cmd.Process.Kill()
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// projectScanMaxDepth and projectScanMaxEntries keep scans of huge repositories fast
+	projectScanMaxDepth   = 6
+	projectScanMaxEntries = 20000
+	// projectScanBudget is the maximum size in bytes of the serialized scan
+	projectScanBudget = 16384
+)
+
+// ProjectScan is a structured summary of the manifests found in a project and its sub-projects
+type ProjectScan struct {
+	// Monorepo is set if the project has several sub-projects or declares workspaces
+	Monorepo bool             `json:"monorepo"`
+	Projects []ScannedProject `json:"projects"`
+	// Ci lists the CI configuration files
+	Ci []string `json:"ci,omitempty"`
+	// Truncated is set if the scan stopped early or parts were left out to fit the budget
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ScannedProject is a directory with at least one manifest, Path is "." for the root
+type ScannedProject struct {
+	Path           string            `json:"path"`
+	Language       string            `json:"language,omitempty"`
+	BuildSystem    string            `json:"buildSystem,omitempty"`
+	Manifests      []string          `json:"manifests"`
+	ToolVersions   map[string]string `json:"toolVersions,omitempty"`
+	MakeTargets    []string          `json:"makeTargets,omitempty"`
+	PackageScripts map[string]string `json:"packageScripts,omitempty"`
+}
+
+// scanManifests are the files that describe how a project is built, run or pinned
+var scanManifests = map[string]bool{
+	"go.mod": true, "go.work": true,
+	"package.json": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true, "pnpm-workspace.yaml": true, "bun.lockb": true,
+	"pyproject.toml": true, "requirements.txt": true, "requirements-dev.txt": true, "poetry.lock": true, "Pipfile": true, "setup.py": true,
+	"Cargo.toml": true, "Cargo.lock": true,
+	"Gemfile": true, "Gemfile.lock": true,
+	"pom.xml": true, "build.gradle": true, "build.gradle.kts": true,
+	"composer.json": true, "mix.exs": true, "CMakeLists.txt": true, "Makefile": true,
+	"Dockerfile": true, "docker-compose.yml": true, "docker-compose.yaml": true, "compose.yml": true, "compose.yaml": true,
+	".tool-versions": true, ".nvmrc": true, ".node-version": true, ".python-version": true, ".ruby-version": true,
+}
+
+// versionFiles map files that pin a single tool to the tool
+var versionFiles = map[string]string{
+	".nvmrc":          "node",
+	".node-version":   "node",
+	".python-version": "python",
+	".ruby-version":   "ruby",
+}
+
+// ciFiles are CI configurations, matched against paths relative to the root
+var ciFiles = []string{
+	".github/workflows/*.yml", ".github/workflows/*.yaml", ".gitlab-ci.yml", ".circleci/config.yml",
+	"Jenkinsfile", "azure-pipelines.yml", ".travis.yml", "bitbucket-pipelines.yml",
+}
+
+// scanSkippedDirs are dependency and cache directories that are skipped even if they aren't ignored
+var scanSkippedDirs = map[string]bool{
+	"node_modules": true, "vendor": true, ".venv": true, "venv": true, "__pycache__": true, ".idk": true,
+}
+
+// ScanProject walks rootDir for manifests, respecting the .gitignore files on the way
+func ScanProject(rootDir string) (*ProjectScan, error) {
+	scan := &ProjectScan{}
+	gitIgnore := LoadGitIgnore(rootDir)
+	projects := map[string]*ScannedProject{}
+	entries := 0
+
+	err := filepath.WalkDir(rootDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// unreadable directories are skipped, the rest of the project is still useful
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(rootDir, filePath)
+		if err != nil || relPath == "." {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		entries++
+		if entries > projectScanMaxEntries {
+			scan.Truncated = true
+			return filepath.SkipAll
+		}
+
+		if entry.IsDir() {
+			name := entry.Name()
+			if scanSkippedDirs[name] || gitIgnore.IsIgnored(relPath, true) ||
+				(strings.HasPrefix(name, ".") && name != ".github" && name != ".circleci") {
+				return filepath.SkipDir
+			}
+			if strings.Count(relPath, "/")+1 > projectScanMaxDepth {
+				scan.Truncated = true
+				return filepath.SkipDir
+			}
+			gitIgnore.AddFile(rootDir, relPath)
+			return nil
+		}
+		if gitIgnore.IsIgnored(relPath, false) {
+			return nil
+		}
+
+		if isCiFile(relPath) {
+			scan.Ci = append(scan.Ci, relPath)
+			return nil
+		}
+		if !scanManifests[entry.Name()] {
+			return nil
+		}
+		dir := path.Dir(relPath)
+		project, ok := projects[dir]
+		if !ok {
+			project = &ScannedProject{Path: dir}
+			projects[dir] = project
+		}
+		project.Manifests = append(project.Manifests, entry.Name())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, project := range projects {
+		readProjectManifests(rootDir, project)
+		if isWorkspaceRoot(rootDir, project) {
+			scan.Monorepo = true
+		}
+		scan.Projects = append(scan.Projects, *project)
+	}
+	sort.Slice(scan.Projects, func(i, j int) bool {
+		return scan.Projects[i].Path < scan.Projects[j].Path
+	})
+	if len(scan.Projects) > 1 {
+		scan.Monorepo = true
+	}
+
+	fitProjectScanToBudget(scan)
+	return scan, nil
+}
+
+func isCiFile(relPath string) bool {
+	for _, pattern := range ciFiles {
+		if matched, _ := path.Match(pattern, relPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// readProjectManifests fills in the language, build system, pinned tool versions, make targets and package scripts
+func readProjectManifests(rootDir string, project *ScannedProject) {
+	sort.Strings(project.Manifests)
+	project.Language, project.BuildSystem = detectLanguageAndBuildSystem(project.Manifests)
+	dir := filepath.Join(rootDir, filepath.FromSlash(project.Path))
+
+	for _, manifest := range project.Manifests {
+		switch {
+		case manifest == ".tool-versions":
+			for tool, version := range readToolVersions(filepath.Join(dir, manifest)) {
+				setToolVersion(project, tool, version)
+			}
+		case versionFiles[manifest] != "":
+			data, err := os.ReadFile(filepath.Join(dir, manifest))
+			if err == nil {
+				setToolVersion(project, versionFiles[manifest], strings.TrimSpace(string(data)))
+			}
+		case manifest == "Makefile":
+			data, err := os.ReadFile(filepath.Join(dir, manifest))
+			if err == nil {
+				project.MakeTargets = ParseMakeTargets(string(data))
+			}
+		case manifest == "package.json":
+			project.PackageScripts = readPackageScripts(filepath.Join(dir, manifest))
+		}
+	}
+}
+
+func setToolVersion(project *ScannedProject, tool string, version string) {
+	if version == "" {
+		return
+	}
+	if project.ToolVersions == nil {
+		project.ToolVersions = map[string]string{}
+	}
+	project.ToolVersions[tool] = version
+}
+
+// readToolVersions reads the `tool version` lines of an asdf or mise .tool-versions file
+func readToolVersions(filePath string) map[string]string {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	versions := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) >= 2 {
+			versions[fields[0]] = fields[1]
+		}
+	}
+	return versions
+}
+
+// isWorkspaceRoot checks if a project declares workspaces with go.work, pnpm, npm or yarn workspaces or a Cargo workspace
+func isWorkspaceRoot(rootDir string, project *ScannedProject) bool {
+	dir := filepath.Join(rootDir, filepath.FromSlash(project.Path))
+	for _, manifest := range project.Manifests {
+		switch manifest {
+		case "go.work", "pnpm-workspace.yaml":
+			return true
+		case "package.json":
+			data, err := os.ReadFile(filepath.Join(dir, manifest))
+			if err != nil {
+				continue
+			}
+			var packageJson struct {
+				Workspaces json.RawMessage `json:"workspaces"`
+			}
+			if json.Unmarshal(data, &packageJson) == nil && len(packageJson.Workspaces) > 0 {
+				return true
+			}
+		case "Cargo.toml":
+			data, err := os.ReadFile(filepath.Join(dir, manifest))
+			if err == nil && strings.Contains(string(data), "[workspace]") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fitProjectScanToBudget trims scripts and targets, then the deepest sub-projects, until the scan fits projectScanBudget
+func fitProjectScanToBudget(scan *ProjectScan) {
+	for {
+		data, err := json.Marshal(scan)
+		if err != nil || len(data) <= projectScanBudget {
+			return
+		}
+		scan.Truncated = true
+
+		trimmed := false
+		for i := range scan.Projects {
+			project := &scan.Projects[i]
+			if len(project.PackageScripts) > 0 || len(project.MakeTargets) > 0 {
+				project.PackageScripts = nil
+				project.MakeTargets = nil
+				trimmed = true
+			}
+		}
+		if trimmed {
+			continue
+		}
+
+		if len(scan.Projects) <= 1 {
+			return
+		}
+		sort.SliceStable(scan.Projects, func(i, j int) bool {
+			return strings.Count(scan.Projects[i].Path, "/") < strings.Count(scan.Projects[j].Path, "/")
+		})
+		scan.Projects = scan.Projects[:len(scan.Projects)/2]
+		sort.Slice(scan.Projects, func(i, j int) bool {
+			return scan.Projects[i].Path < scan.Projects[j].Path
+		})
+	}
+}
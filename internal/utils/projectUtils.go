@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// projectContextBudget is the maximum size in bytes of the serialized project context
+const projectContextBudget = 4096
+
+// ProjectContext is a compact summary of the repository a prompt is run from
+type ProjectContext struct {
+	GitBranch      string            `json:"gitBranch,omitempty"`
+	GitStatus      string            `json:"gitStatus,omitempty"`
+	Language       string            `json:"language,omitempty"`
+	BuildSystem    string            `json:"buildSystem,omitempty"`
+	Files          []string          `json:"files,omitempty"`
+	MakeTargets    []string          `json:"makeTargets,omitempty"`
+	PackageScripts map[string]string `json:"packageScripts,omitempty"`
+}
+
+// projectMarkers maps well known manifest files to the language and build system they imply.
+// The first marker found wins, so more specific markers come first.
+var projectMarkers = []struct {
+	file        string
+	language    string
+	buildSystem string
+}{
+	{"go.mod", "Go", "go"},
+	{"Cargo.toml", "Rust", "cargo"},
+	{"pnpm-lock.yaml", "JavaScript", "pnpm"},
+	{"yarn.lock", "JavaScript", "yarn"},
+	{"package.json", "JavaScript", "npm"},
+	{"poetry.lock", "Python", "poetry"},
+	{"pyproject.toml", "Python", "pip"},
+	{"requirements.txt", "Python", "pip"},
+	{"pom.xml", "Java", "maven"},
+	{"build.gradle", "Java", "gradle"},
+	{"build.gradle.kts", "Kotlin", "gradle"},
+	{"Gemfile", "Ruby", "bundler"},
+	{"composer.json", "PHP", "composer"},
+	{"mix.exs", "Elixir", "mix"},
+	{"CMakeLists.txt", "C/C++", "cmake"},
+	{"Makefile", "", "make"},
+}
+
+var makeTargetRegex = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_.\-/ ]*):([^=]|$)`)
+
+// GatherProjectContext collects the project context of the current working directory.
+// It returns nil if the directory is not inside a git repository.
+func GatherProjectContext() (*ProjectContext, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsGitRepository(pwd) {
+		return nil, nil
+	}
+
+	projectContext := &ProjectContext{
+		GitBranch: gitOutput(pwd, "rev-parse", "--abbrev-ref", "HEAD"),
+		GitStatus: gitStatusSummary(pwd),
+	}
+
+	files, err := ListFilesAndDirs()
+	if err != nil {
+		return nil, err
+	}
+	gitIgnore := LoadGitIgnore(pwd)
+	for _, file := range files {
+		info, err := os.Stat(filepath.Join(pwd, file))
+		if err != nil || gitIgnore.IsIgnored(file, info.IsDir()) {
+			continue
+		}
+		projectContext.Files = append(projectContext.Files, file)
+	}
+
+	projectContext.Language, projectContext.BuildSystem = detectLanguageAndBuildSystem(projectContext.Files)
+
+	makefileData, err := FindMakefileData()
+	if err == nil {
+		projectContext.MakeTargets = ParseMakeTargets(makefileData)
+	}
+	projectContext.PackageScripts = readPackageScripts(filepath.Join(pwd, "package.json"))
+
+	fitProjectContextToBudget(projectContext)
+
+	return projectContext, nil
+}
+
+// IsGitRepository checks if dir is inside a git work tree
+func IsGitRepository(dir string) bool {
+	return gitOutput(dir, "rev-parse", "--is-inside-work-tree") == "true"
+}
+
+func gitOutput(dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// gitStatusSummary turns `git status --porcelain` into a short summary like "2 modified, 1 untracked"
+func gitStatusSummary(dir string) string {
+	cmd := exec.Command("git", "status", "--porcelain")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	counts := map[string]int{}
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 2 {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "??"):
+			counts["untracked"]++
+		case line[0] != ' ':
+			counts["staged"]++
+		default:
+			counts["modified"]++
+		}
+	}
+
+	if len(counts) == 0 {
+		return "clean"
+	}
+
+	var parts []string
+	for _, state := range []string{"staged", "modified", "untracked"} {
+		if counts[state] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[state], state))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func detectLanguageAndBuildSystem(files []string) (string, string) {
+	fileSet := map[string]bool{}
+	for _, file := range files {
+		fileSet[file] = true
+	}
+
+	language, buildSystem := "", ""
+	for _, marker := range projectMarkers {
+		if !fileSet[marker.file] {
+			continue
+		}
+		if language == "" {
+			language = marker.language
+		}
+		if buildSystem == "" {
+			buildSystem = marker.buildSystem
+		}
+		if language != "" && buildSystem != "" {
+			break
+		}
+	}
+	return language, buildSystem
+}
+
+// ParseMakeTargets returns the explicit targets of a Makefile, skipping special and pattern targets
+func ParseMakeTargets(makefileData string) []string {
+	var targets []string
+	seen := map[string]bool{}
+
+	scanner := bufio.NewScanner(strings.NewReader(makefileData))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\t") || strings.HasPrefix(line, ".") {
+			continue
+		}
+		match := makeTargetRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		for _, target := range strings.Fields(match[1]) {
+			if strings.Contains(target, "%") || seen[target] {
+				continue
+			}
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+func readPackageScripts(packageJsonPath string) map[string]string {
+	data, err := os.ReadFile(packageJsonPath)
+	if err != nil {
+		return nil
+	}
+
+	var packageJson struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &packageJson); err != nil {
+		return nil
+	}
+	return packageJson.Scripts
+}
+
+// fitProjectContextToBudget trims the least important parts of the context until it fits projectContextBudget
+func fitProjectContextToBudget(projectContext *ProjectContext) {
+	const maxScriptLength = 200
+	for name, script := range projectContext.PackageScripts {
+		if len(script) > maxScriptLength {
+			projectContext.PackageScripts[name] = script[:maxScriptLength] + "..."
+		}
+	}
+
+	for {
+		data, err := json.Marshal(projectContext)
+		if err != nil || len(data) <= projectContextBudget {
+			return
+		}
+
+		switch {
+		case len(projectContext.Files) > 0:
+			projectContext.Files = projectContext.Files[:len(projectContext.Files)/2]
+		case len(projectContext.PackageScripts) > 0:
+			names := make([]string, 0, len(projectContext.PackageScripts))
+			for name := range projectContext.PackageScripts {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			delete(projectContext.PackageScripts, names[len(names)-1])
+		case len(projectContext.MakeTargets) > 0:
+			projectContext.MakeTargets = projectContext.MakeTargets[:len(projectContext.MakeTargets)/2]
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	RiskLow    = "low"
+	RiskMedium = "medium"
+	RiskHigh   = "high"
+)
+
+var riskLevels = map[string]int{
+	RiskLow:    0,
+	RiskMedium: 1,
+	RiskHigh:   2,
+}
+
+// highRiskPatterns match commands that can destroy data or compromise the machine
+var highRiskPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\brm\s+(-[a-zA-Z]*[rRf][a-zA-Z]*\s+)+`),        // recursive or forced rm
+	regexp.MustCompile(`\b(mkfs(\.\w+)?|fdisk|parted|wipefs)\b`),       // disk formatting and partitioning
+	regexp.MustCompile(`\bdd\s+.*\bof=`),                               // raw writes with dd
+	regexp.MustCompile(`(curl|wget)\s[^|]*\|\s*(sudo\s+)?(ba|z)?sh\b`), // piping downloads into a shell
+	regexp.MustCompile(`>\s*/dev/(sd|nvme|hd|disk)`),                   // redirecting into block devices
+	regexp.MustCompile(`\bchmod\s+(-r\s+)?[0-7]*777\s+/`),              // world writable system paths
+	regexp.MustCompile(`:\(\)\s*\{\s*:\|:&\s*\};:`),                    // fork bomb
+	regexp.MustCompile(`\bgit\s+(push\s+.*(--force|-f)\b|reset\s+--hard|clean\s+-[a-zA-Z]*f)`),
+	regexp.MustCompile(`\b(shutdown|reboot|halt|poweroff)\b`),
+	regexp.MustCompile(`\b(drop\s+(database|table)|truncate\s+table)\b`),
+}
+
+// mediumRiskPatterns match commands that change the system or files but are usually recoverable
+var mediumRiskPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\bsudo\b`),
+	regexp.MustCompile(`\b(rm|rmdir|mv|chmod|chown|chgrp|truncate|shred|unlink|ln)\b`),
+	regexp.MustCompile(`\bsed\s+(-[a-zA-Z]*i|--in-place)`),
+	regexp.MustCompile(`[^>&0-9]>\s*[^&\s]`), // redirecting output into a file
+	regexp.MustCompile(`\b(kill|pkill|killall)\b`),
+	regexp.MustCompile(`\b(apt|apt-get|yum|dnf|pacman|apk|brew|pip|pip3|npm|yarn|pnpm|gem|cargo)\s+(install|remove|uninstall|upgrade|update|purge)\b`),
+	regexp.MustCompile(`\bgit\s+(push|rebase|commit|merge|checkout|stash)\b`),
+	regexp.MustCompile(`\b(docker|podman)\s+(rm|rmi|system\s+prune|volume\s+rm)\b`),
+	regexp.MustCompile(`\bkubectl\s+(delete|apply|scale)\b`),
+}
+
+// AssessCommandRisk estimates how dangerous it is to run a command or script
+func AssessCommandRisk(command string) string {
+	lowerCommand := strings.ToLower(command)
+	for _, pattern := range highRiskPatterns {
+		if pattern.MatchString(lowerCommand) {
+			return RiskHigh
+		}
+	}
+	for _, pattern := range mediumRiskPatterns {
+		if pattern.MatchString(lowerCommand) {
+			return RiskMedium
+		}
+	}
+	return RiskLow
+}
+
+// IsValidRisk checks if risk is one of the known risk levels
+func IsValidRisk(risk string) bool {
+	_, ok := riskLevels[strings.ToLower(risk)]
+	return ok
+}
+
+// MaxRisk returns the higher of two risk levels. Unknown levels are ignored.
+func MaxRisk(a string, b string) string {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if !IsValidRisk(a) {
+		return b
+	}
+	if !IsValidRisk(b) {
+		return a
+	}
+	if riskLevels[a] >= riskLevels[b] {
+		return a
+	}
+	return b
+}
+
+// IsRiskAbove checks if risk is strictly higher than limit
+func IsRiskAbove(risk string, limit string) bool {
+	return riskLevels[strings.ToLower(risk)] > riskLevels[strings.ToLower(limit)]
+}
@@ -0,0 +1,84 @@
+package utils
+
+import "testing"
+
+func TestAssessCommandRisk(t *testing.T) {
+	tests := []struct {
+		command string
+		want    string
+	}{
+		{"ls -la", RiskLow},
+		{"git status && git log --oneline", RiskLow},
+		{"grep -r TODO . | wc -l", RiskLow},
+		{"find . -name '*.go' 2>/dev/null", RiskLow},
+		{"echo done >&2", RiskLow},
+		{"rm notes.txt", RiskMedium},
+		{"mv a.txt b.txt", RiskMedium},
+		{"sudo systemctl restart nginx", RiskMedium},
+		{"sed -i 's/a/b/' file.txt", RiskMedium},
+		{"echo hi > out.txt", RiskMedium},
+		{"cat a.txt >> b.txt", RiskMedium},
+		{"npm install express", RiskMedium},
+		{"git push origin main", RiskMedium},
+		{"kubectl apply -f deploy.yaml", RiskMedium},
+		{"rm -rf build", RiskHigh},
+		{"RM -RF /tmp/x", RiskHigh},
+		{"rm -f -r node_modules", RiskHigh},
+		{"mkfs.ext4 /dev/sdb1", RiskHigh},
+		{"dd if=image.iso of=/dev/sdb bs=4M", RiskHigh},
+		{"curl -fsSL https://example.com/install.sh | bash", RiskHigh},
+		{"wget -qO- https://example.com/x | sudo sh", RiskHigh},
+		{"chmod -R 777 /var", RiskHigh},
+		{"git push --force origin main", RiskHigh},
+		{"git reset --hard HEAD~1", RiskHigh},
+		{"git clean -fd", RiskHigh},
+		{"psql -c 'DROP TABLE users'", RiskHigh},
+		{"sudo reboot", RiskHigh},
+		{"#!/bin/bash\nset -e\nrm -rf \"$TMP\"", RiskHigh},
+	}
+
+	for _, test := range tests {
+		if got := AssessCommandRisk(test.command); got != test.want {
+			t.Errorf("AssessCommandRisk(%q) = %s, want %s", test.command, got, test.want)
+		}
+	}
+}
+
+func TestMaxRisk(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want string
+	}{
+		{RiskLow, RiskHigh, RiskHigh},
+		{RiskMedium, RiskLow, RiskMedium},
+		{"HIGH", RiskMedium, RiskHigh},
+		{"", RiskMedium, RiskMedium},
+		{RiskLow, "unknown", RiskLow},
+	}
+
+	for _, test := range tests {
+		if got := MaxRisk(test.a, test.b); got != test.want {
+			t.Errorf("MaxRisk(%q, %q) = %s, want %s", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestIsRiskAbove(t *testing.T) {
+	tests := []struct {
+		risk  string
+		limit string
+		want  bool
+	}{
+		{RiskHigh, RiskMedium, true},
+		{RiskMedium, RiskMedium, false},
+		{RiskLow, RiskMedium, false},
+		{"High", RiskLow, true},
+	}
+
+	for _, test := range tests {
+		if got := IsRiskAbove(test.risk, test.limit); got != test.want {
+			t.Errorf("IsRiskAbove(%q, %q) = %v, want %v", test.risk, test.limit, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,435 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	SandboxBubblewrap = "bwrap"
+	SandboxPodman     = "podman"
+	SandboxDocker     = "docker"
+)
+
+// sandboxCopyBudget is the maximum number of bytes copied into a sandbox, dependency directories included
+const sandboxCopyBudget = 1024 * 1024 * 1024
+
+// sandboxBulkyDirs are copied into the sandbox like everything else, but changes inside them are reported
+// once for the whole directory, like for the directories .gitignore excludes. They hold many files nobody
+// wants to review one by one.
+var sandboxBulkyDirs = map[string]bool{
+	".git": true, "node_modules": true, ".venv": true, "venv": true, "__pycache__": true,
+}
+
+const (
+	FileAdded    = "added"
+	FileModified = "modified"
+	FileDeleted  = "deleted"
+)
+
+// FileChange is a difference between the project and its copy in the sandbox
+type FileChange struct {
+	// Path is relative to the project directory
+	Path string
+	Kind string
+}
+
+// SandboxExecutor runs commands and scripts against a copy of the current directory, inside
+// bubblewrap or a rootless container. The rest of the file system is read-only (bubblewrap) or
+// not visible at all (containers). Changes to the copy are only applied to the project if Review approves them.
+type SandboxExecutor struct {
+	Backend string
+	// Image is the container image used by the podman and docker backends
+	Image string
+	// Review is called after every run with the changes made in the sandbox, also when there are none,
+	// and decides if they are applied to the project
+	Review func(changes []FileChange) bool
+}
+
+// DetectSandboxBackend returns the best isolation tool installed on this machine
+func DetectSandboxBackend() (string, error) {
+	for _, backend := range []string{SandboxBubblewrap, SandboxPodman, SandboxDocker} {
+		if _, err := exec.LookPath(backend); err == nil {
+			return backend, nil
+		}
+	}
+	return "", fmt.Errorf("no sandbox available. Install bubblewrap (bwrap), podman or docker")
+}
+
+func (e SandboxExecutor) RunCommand(commandStr string) error {
+	return e.run("", []string{"/bin/sh", "-c", commandStr}, ScriptRunOptions{})
+}
+
+func (e SandboxExecutor) RunScript(script string, language ScriptLanguage, options ScriptRunOptions) error {
+	// bubblewrap sees the host's programs, containers bring their own
+	if e.Backend == SandboxBubblewrap {
+		if err := CheckInterpreter(language); err != nil {
+			return err
+		}
+	}
+
+	filePath, cleanup, err := writeScriptFile(script, language)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	args := append(append([]string{}, language.Interpreter...), filePath)
+	return e.run(filepath.Dir(filePath), args, options)
+}
+
+// run executes args in the sandbox with a writable copy of the working directory mounted in its place,
+// then passes the changes to Review and applies them if approved
+func (e SandboxExecutor) run(scriptDir string, args []string, options ScriptRunOptions) error {
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	sandboxDir, err := os.MkdirTemp("", "idk-sandbox-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(sandboxDir)
+
+	copyDir := filepath.Join(sandboxDir, "project")
+	bulkyDirs, err := copyProjectForSandbox(projectDir, copyDir)
+	if err != nil {
+		return fmt.Errorf("failed to copy project into the sandbox: %w", err)
+	}
+
+	sandboxArgs := e.sandboxArgs(projectDir, copyDir, scriptDir, args, options.Env)
+	cmd := exec.Command(sandboxArgs[0], sandboxArgs[1:]...)
+	if len(options.Env) > 0 {
+		cmd.Env = append(os.Environ(), options.Env...)
+	}
+	runErr := runScriptProcess(cmd, options)
+
+	changes, err := DiffTrees(projectDir, copyDir, bulkyDirs)
+	if err != nil {
+		return err
+	}
+	if e.Review(changes) && len(changes) > 0 {
+		if err := applyChanges(projectDir, copyDir, changes); err != nil {
+			return fmt.Errorf("failed to apply sandbox changes: %w", err)
+		}
+	}
+	return runErr
+}
+
+// copyProjectForSandbox copies projectDir to copyDir and returns the bulky directories in it, .git, dependency
+// directories and the directories .gitignore excludes, relative to projectDir. Projects larger than
+// sandboxCopyBudget are refused.
+func copyProjectForSandbox(projectDir string, copyDir string) ([]string, error) {
+	gitIgnore := LoadGitIgnore(projectDir)
+	var bulkyDirs []string
+	var size int64
+	err := filepath.WalkDir(projectDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(projectDir, path)
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && relPath != "." && collapsedDir(relPath, bulkyDirs) == "" {
+			if sandboxBulkyDirs[entry.Name()] || gitIgnore.IsIgnored(filepath.ToSlash(relPath), true) {
+				bulkyDirs = append(bulkyDirs, relPath)
+			} else {
+				gitIgnore.AddFile(projectDir, filepath.ToSlash(relPath))
+			}
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+			if size > sandboxCopyBudget {
+				return fmt.Errorf("the project is larger than %d MB, too large to copy into a sandbox", sandboxCopyBudget/1024/1024)
+			}
+		}
+		return copyEntry(path, filepath.Join(copyDir, relPath), entry.Type())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return bulkyDirs, nil
+}
+
+func (e SandboxExecutor) sandboxArgs(projectDir string, copyDir string, scriptDir string, args []string, env []string) []string {
+	var sandboxArgs []string
+
+	switch e.Backend {
+	case SandboxBubblewrap:
+		sandboxArgs = []string{
+			"bwrap",
+			"--ro-bind", "/", "/",
+			"--dev", "/dev",
+			"--proc", "/proc",
+			"--tmpfs", "/tmp",
+			"--unshare-all", "--share-net",
+			"--die-with-parent",
+		}
+		if scriptDir != "" {
+			sandboxArgs = append(sandboxArgs, "--ro-bind", scriptDir, scriptDir)
+		}
+		sandboxArgs = append(sandboxArgs, "--bind", copyDir, projectDir, "--chdir", projectDir, "--")
+	default:
+		sandboxArgs = []string{e.Backend, "run", "--rm", "-i"}
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			sandboxArgs = append(sandboxArgs, "-t")
+		}
+		// keep files written in the container owned by the current user
+		if e.Backend == SandboxPodman {
+			sandboxArgs = append(sandboxArgs, "--userns=keep-id")
+		} else {
+			sandboxArgs = append(sandboxArgs, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+		}
+		if scriptDir != "" {
+			sandboxArgs = append(sandboxArgs, "-v", scriptDir+":"+scriptDir+":ro")
+		}
+		for _, variable := range env {
+			sandboxArgs = append(sandboxArgs, "-e", variable)
+		}
+		sandboxArgs = append(sandboxArgs, "-v", copyDir+":"+projectDir, "-w", projectDir, e.Image)
+	}
+
+	return append(sandboxArgs, args...)
+}
+
+// CopyTree copies the directory src to dst, keeping file modes and symlinks
+func CopyTree(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		return copyEntry(path, filepath.Join(dst, relPath), entry.Type())
+	})
+}
+
+func copyEntry(src string, dst string, fileType fs.FileMode) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case fileType.IsDir():
+		return os.MkdirAll(dst, info.Mode().Perm())
+	case fileType&fs.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case fileType.IsRegular():
+		return copyFile(src, dst, info.Mode().Perm())
+	default:
+		// sockets, devices and pipes can not be copied
+		return nil
+	}
+}
+
+func copyFile(src string, dst string, mode fs.FileMode) error {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	if _, err := io.Copy(dstFile, srcFile); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+// DiffTrees lists the changes that turn directory before into directory after. Added and deleted directories
+// are reported once, without their contents, and so are changes inside the relative directories collapse,
+// as a modification of the whole directory.
+func DiffTrees(before string, after string, collapse []string) ([]FileChange, error) {
+	beforeEntries, err := listTree(before)
+	if err != nil {
+		return nil, err
+	}
+	afterEntries, err := listTree(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for path := range beforeEntries {
+		paths = append(paths, path)
+	}
+	for path := range afterEntries {
+		if _, ok := beforeEntries[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var changes []FileChange
+	var reportedDir string
+	for _, path := range paths {
+		if reportedDir != "" && strings.HasPrefix(path, reportedDir+string(filepath.Separator)) {
+			continue
+		}
+
+		beforeInfo, inBefore := beforeEntries[path]
+		afterInfo, inAfter := afterEntries[path]
+		kind := ""
+		switch {
+		case !inAfter:
+			kind = FileDeleted
+		case !inBefore:
+			kind = FileAdded
+		case !sameEntry(filepath.Join(before, path), beforeInfo, filepath.Join(after, path), afterInfo):
+			kind = FileModified
+		}
+		if kind == "" {
+			continue
+		}
+		if dir := collapsedDir(path, collapse); dir != "" {
+			changes = append(changes, FileChange{Path: dir, Kind: FileModified})
+			reportedDir = dir
+			continue
+		}
+
+		changes = append(changes, FileChange{Path: path, Kind: kind})
+		if slices.Contains(collapse, path) || kind != FileModified && (beforeInfo != nil && beforeInfo.IsDir() || afterInfo != nil && afterInfo.IsDir()) {
+			reportedDir = path
+		}
+	}
+	return changes, nil
+}
+
+// collapsedDir returns the directory of collapse that path is inside, or "" if there is none
+func collapsedDir(path string, collapse []string) string {
+	for _, dir := range collapse {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return dir
+		}
+	}
+	return ""
+}
+
+func listTree(root string) (map[string]fs.FileInfo, error) {
+	entries := map[string]fs.FileInfo{}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		entries[relPath] = info
+		return nil
+	})
+	return entries, err
+}
+
+func sameEntry(beforePath string, beforeInfo fs.FileInfo, afterPath string, afterInfo fs.FileInfo) bool {
+	if beforeInfo.Mode() != afterInfo.Mode() {
+		return false
+	}
+
+	switch {
+	case beforeInfo.IsDir():
+		return true
+	case beforeInfo.Mode()&fs.ModeSymlink != 0:
+		beforeTarget, _ := os.Readlink(beforePath)
+		afterTarget, _ := os.Readlink(afterPath)
+		return beforeTarget == afterTarget
+	case beforeInfo.Mode().IsRegular():
+		return beforeInfo.Size() == afterInfo.Size() && sameContent(beforePath, afterPath)
+	default:
+		return true
+	}
+}
+
+func sameContent(a string, b string) bool {
+	fileA, err := os.Open(a)
+	if err != nil {
+		return false
+	}
+	defer fileA.Close()
+	fileB, err := os.Open(b)
+	if err != nil {
+		return false
+	}
+	defer fileB.Close()
+
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+	for {
+		nA, errA := io.ReadFull(fileA, bufA)
+		nB, errB := io.ReadFull(fileB, bufB)
+		if nA != nB || !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false
+		}
+		if errA == io.EOF || errA == io.ErrUnexpectedEOF {
+			return errB == io.EOF || errB == io.ErrUnexpectedEOF
+		}
+		if errA != nil || errB != nil {
+			return false
+		}
+	}
+}
+
+// applyChanges copies the changes from the sandbox copy back into the project
+func applyChanges(projectDir string, copyDir string, changes []FileChange) error {
+	for _, change := range changes {
+		projectPath := filepath.Join(projectDir, change.Path)
+		copyPath := filepath.Join(copyDir, change.Path)
+
+		if err := os.RemoveAll(projectPath); err != nil {
+			return err
+		}
+		if change.Kind == FileDeleted {
+			continue
+		}
+
+		info, err := os.Lstat(copyPath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := CopyTree(copyPath, projectPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := copyEntry(copyPath, projectPath, info.Mode().Type()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
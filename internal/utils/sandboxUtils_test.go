@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSandboxCopyDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		// command changes the copy of the project, like a command run in the sandbox
+		command string
+		want    []FileChange
+	}{
+		{"no changes", "true", nil},
+		{"cleanup of dependencies and ignored output", "rm -rf node_modules build/", []FileChange{
+			{Path: "build", Kind: FileDeleted},
+			{Path: "node_modules", Kind: FileDeleted},
+		}},
+		{"changes inside dependencies", "echo changed > node_modules/pkg/index.js && touch node_modules/new.js", []FileChange{
+			{Path: "node_modules", Kind: FileModified},
+		}},
+		{"changes to project files", "echo changed > src/main.js && touch src/extra.js", []FileChange{
+			{Path: filepath.Join("src", "extra.js"), Kind: FileAdded},
+			{Path: filepath.Join("src", "main.js"), Kind: FileModified},
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			projectDir := t.TempDir()
+			writeTestFile(t, filepath.Join(projectDir, ".gitignore"), "build/\n")
+			writeTestFile(t, filepath.Join(projectDir, "src", "main.js"), "main")
+			writeTestFile(t, filepath.Join(projectDir, "node_modules", "pkg", "index.js"), "pkg")
+			writeTestFile(t, filepath.Join(projectDir, "build", "main.min.js"), "built")
+
+			copyDir := filepath.Join(t.TempDir(), "project")
+			bulkyDirs, err := copyProjectForSandbox(projectDir, copyDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command("/bin/sh", "-c", test.command)
+			cmd.Dir = copyDir
+			if err := cmd.Run(); err != nil {
+				t.Fatal(err)
+			}
+
+			changes, err := DiffTrees(projectDir, copyDir, bulkyDirs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(changes, test.want) {
+				t.Fatalf("DiffTrees() = %v, want %v", changes, test.want)
+			}
+
+			if err := applyChanges(projectDir, copyDir, changes); err != nil {
+				t.Fatal(err)
+			}
+			if left, err := DiffTrees(projectDir, copyDir, nil); err != nil || len(left) > 0 {
+				t.Errorf("after applyChanges() the project differs from the sandbox: %v, %v", left, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ScriptLanguage describes how scripts of one language are stored and executed
+type ScriptLanguage struct {
+	Name      string
+	Extension string
+	// Shebang is written as the first line of saved scripts
+	Shebang string
+	// Interpreter is the command that runs a script file, the file path is appended to it
+	Interpreter []string
+}
+
+var (
+	ShellLanguage = ScriptLanguage{
+		Name:        "shell",
+		Extension:   ".sh",
+		Shebang:     "#!/bin/sh",
+		Interpreter: []string{"sh"},
+	}
+	BashLanguage = ScriptLanguage{
+		Name:        "bash",
+		Extension:   ".sh",
+		Shebang:     "#!/usr/bin/env bash",
+		Interpreter: []string{"bash"},
+	}
+	PythonLanguage = ScriptLanguage{
+		Name:        "python",
+		Extension:   ".py",
+		Shebang:     "#!/usr/bin/env python3",
+		Interpreter: []string{"python3"},
+	}
+	NodeLanguage = ScriptLanguage{
+		Name:        "node",
+		Extension:   ".js",
+		Shebang:     "#!/usr/bin/env node",
+		Interpreter: []string{"node"},
+	}
+	// Go has no shebang support, the first line is a comment for Go and a command for the shell
+	GoLanguage = ScriptLanguage{
+		Name:        "go",
+		Extension:   ".go",
+		Shebang:     `//usr/bin/env go run "$0" "$@"; exit "$?"`,
+		Interpreter: []string{"go", "run"},
+	}
+)
+
+// scriptLanguageAliases maps the names the backend and shebangs use to a language
+var scriptLanguageAliases = map[string]ScriptLanguage{
+	"shell":      ShellLanguage,
+	"sh":         ShellLanguage,
+	"bash":       BashLanguage,
+	"zsh":        BashLanguage,
+	"python":     PythonLanguage,
+	"python3":    PythonLanguage,
+	"py":         PythonLanguage,
+	"node":       NodeLanguage,
+	"nodejs":     NodeLanguage,
+	"javascript": NodeLanguage,
+	"js":         NodeLanguage,
+	"go":         GoLanguage,
+	"golang":     GoLanguage,
+}
+
+// DetectScriptLanguage picks the language of a script from the declared language, falling back to
+// the shebang. The content is never guessed from, so everything else is treated as shell.
+func DetectScriptLanguage(declared string, script string) ScriptLanguage {
+	if language, ok := scriptLanguageAliases[strings.ToLower(strings.TrimSpace(declared))]; ok {
+		return language
+	}
+
+	firstLine := strings.TrimSpace(strings.SplitN(strings.TrimSpace(script), "\n", 2)[0])
+	if strings.HasPrefix(firstLine, "#!") {
+		fields := strings.Fields(strings.TrimPrefix(firstLine, "#!"))
+		if len(fields) > 0 {
+			interpreter := filepath.Base(fields[0])
+			if interpreter == "env" && len(fields) > 1 {
+				interpreter = fields[1]
+			}
+			if language, ok := scriptLanguageAliases[interpreter]; ok {
+				return language
+			}
+		}
+	}
+
+	// Go has no shebang, saved Go scripts start with the line GoLanguage.Shebang writes instead
+	if strings.HasPrefix(firstLine, "//usr/bin/env go ") {
+		return GoLanguage
+	}
+	return ShellLanguage
+}
+
+// CheckInterpreter returns an error if the interpreter of language is not installed
+func CheckInterpreter(language ScriptLanguage) error {
+	if _, err := exec.LookPath(language.Interpreter[0]); err != nil {
+		return fmt.Errorf("%s is required to run %s scripts but was not found", language.Interpreter[0], language.Name)
+	}
+	return nil
+}
+
+// WithShebang returns the script with the shebang of language as its first line, unless it already has one
+func WithShebang(script string, language ScriptLanguage) string {
+	if strings.HasPrefix(script, "#!") || strings.HasPrefix(script, language.Shebang) {
+		return script
+	}
+	return language.Shebang + "\n" + script
+}
+
+// StrictScript turns on fail-fast options for shell scripts (`set -euo pipefail`).
+// Bash is used when available since plain sh may not support pipefail. Other languages are returned unchanged.
+func StrictScript(script string, language ScriptLanguage) (string, ScriptLanguage) {
+	if language.Name != ShellLanguage.Name && language.Name != BashLanguage.Name {
+		return script, language
+	}
+
+	strictOptions := "set -euo pipefail"
+	if _, err := exec.LookPath("bash"); err == nil {
+		language = BashLanguage
+	} else {
+		strictOptions = "set -eu"
+	}
+
+	// keep an existing shebang as the first line
+	if strings.HasPrefix(script, "#!") {
+		lines := strings.SplitN(script, "\n", 2)
+		if len(lines) == 1 {
+			return lines[0] + "\n" + strictOptions, language
+		}
+		return lines[0] + "\n" + strictOptions + "\n" + lines[1], language
+	}
+	return strictOptions + "\n" + script, language
+}
+
+// ScriptRunOptions control how RunScript executes a script
+type ScriptRunOptions struct {
+	// Timeout stops the script after the given duration, 0 means no timeout
+	Timeout time.Duration
+	// Env are extra `key=value` environment variables for the script
+	Env []string
+}
+
+// scriptKillGracePeriod is how long a script may take to exit after SIGTERM before it is killed
+const scriptKillGracePeriod = 5 * time.Second
+
+// RunScript writes the script to a private temp directory and runs it with the interpreter of its
+// language in a new process. Interrupts are forwarded to the script, the temp directory is always
+// removed and the script's exit status is returned as an *exec.ExitError.
+func RunScript(script string, language ScriptLanguage, options ScriptRunOptions) error {
+	if err := CheckInterpreter(language); err != nil {
+		return err
+	}
+
+	filePath, cleanup, err := writeScriptFile(script, language)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	args := append(append([]string{}, language.Interpreter[1:]...), filePath)
+	cmd := exec.Command(language.Interpreter[0], args...)
+	if len(options.Env) > 0 {
+		cmd.Env = append(os.Environ(), options.Env...)
+	}
+	return runScriptProcess(cmd, options)
+}
+
+// writeScriptFile writes the script to a new private temp directory. cleanup removes the directory.
+func writeScriptFile(script string, language ScriptLanguage) (string, func(), error) {
+	tempDir, err := os.MkdirTemp("", "idk-script-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() {
+		os.RemoveAll(tempDir)
+	}
+
+	filePath := filepath.Join(tempDir, "script"+language.Extension)
+	if err := os.WriteFile(filePath, []byte(script), 0700); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return filePath, cleanup, nil
+}
+
+// runScriptProcess runs cmd connected to the terminal in its own process group,
+// forwarding interrupts and stopping it once options.Timeout is reached
+func runScriptProcess(cmd *exec.Cmd, options ScriptRunOptions) error {
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	// catch interrupts before starting so idk stays alive to clean up after the script
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	restoreTerminal := useOwnProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		restoreTerminal()
+		return err
+	}
+	defer restoreTerminal()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeout <-chan time.Time
+	if options.Timeout > 0 {
+		timer := time.NewTimer(options.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var kill <-chan time.Time
+	timedOut := false
+
+	for {
+		select {
+		case err := <-done:
+			if timedOut {
+				return fmt.Errorf("script timed out after %s", options.Timeout)
+			}
+			return err
+		case sig := <-signals:
+			_ = signalProcessGroup(cmd, sig.(syscall.Signal))
+		case <-timeout:
+			timedOut = true
+			_ = signalProcessGroup(cmd, syscall.SIGTERM)
+			kill = time.After(scriptKillGracePeriod)
+		case <-kill:
+			_ = signalProcessGroup(cmd, syscall.SIGKILL)
+		}
+	}
+}
+
+// ExitCode returns the exit code of a finished command like a shell reports it: 0 for success,
+// 128 + the signal number for commands killed by a signal and 1 for errors without a code
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 1
+	}
+	if exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode()
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return 1
+}
@@ -0,0 +1,34 @@
+package utils
+
+import "testing"
+
+func TestDetectScriptLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		declared string
+		script   string
+		want     string
+	}{
+		{"declared language", "python", "echo hi", PythonLanguage.Name},
+		{"declared alias", " JavaScript ", "", NodeLanguage.Name},
+		{"declared beats shebang", "bash", "#!/usr/bin/env python3\nprint(1)", BashLanguage.Name},
+		{"env shebang", "", "#!/usr/bin/env node\nconsole.log(1)", NodeLanguage.Name},
+		{"absolute shebang", "", "#!/bin/bash\necho hi", BashLanguage.Name},
+		{"shebang after blank lines", "", "\n\n#!/usr/bin/python3\nprint(1)", PythonLanguage.Name},
+		{"go shebang line", "", GoLanguage.Shebang + "\npackage main", GoLanguage.Name},
+		{"unknown shebang", "", "#!/usr/bin/env ruby\nputs 1", ShellLanguage.Name},
+		{"unknown declared language", "cobol", "echo hi", ShellLanguage.Name},
+		{"shell using def", "", "def=1\necho \"def $def\"", ShellLanguage.Name},
+		{"shell using require(", "", "grep 'require(' *.js", ShellLanguage.Name},
+		{"python without shebang", "", "import os\nprint(os.getcwd())", ShellLanguage.Name},
+		{"empty", "", "", ShellLanguage.Name},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := DetectScriptLanguage(test.declared, test.script); got.Name != test.want {
+				t.Errorf("DetectScriptLanguage(%q, %q) = %s, want %s", test.declared, test.script, got.Name, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Formats `idk setup --export` writes the setup steps in
+const (
+	SetupExportScript       = "script"
+	SetupExportMakefile     = "makefile"
+	SetupExportDevcontainer = "devcontainer"
+	SetupExportContributing = "contributing"
+)
+
+var SetupExportFormats = []string{SetupExportScript, SetupExportMakefile, SetupExportDevcontainer, SetupExportContributing}
+
+// devcontainerImage is the base image of exported devcontainers, the setup steps install everything else
+const devcontainerImage = "mcr.microsoft.com/devcontainers/base:ubuntu"
+
+// ExportSetup writes the steps of state to the project in projectDir in format and returns the
+// paths of the changed files. Existing files are extended where that is safe, never overwritten.
+func ExportSetup(projectDir string, state *SetupState, format string) ([]string, error) {
+	switch format {
+	case SetupExportScript:
+		path := filepath.Join(projectDir, "setup.sh")
+		return []string{path}, createFile(path, SetupScript(state), 0755)
+	case SetupExportMakefile:
+		return exportMakefile(projectDir, state)
+	case SetupExportDevcontainer:
+		dir := filepath.Join(projectDir, ".devcontainer")
+		files := []string{filepath.Join(dir, "devcontainer.json"), filepath.Join(dir, "Dockerfile"), filepath.Join(dir, "setup.sh")}
+		for _, file := range files {
+			if _, err := os.Stat(file); err == nil {
+				return nil, fmt.Errorf("%s already exists", file)
+			}
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+		devcontainerJson, err := SetupDevcontainerJson(filepath.Base(projectDir))
+		if err != nil {
+			return nil, err
+		}
+		if err := createFile(files[0], devcontainerJson, 0644); err != nil {
+			return nil, err
+		}
+		if err := createFile(files[1], fmt.Sprintf("FROM %s\n", devcontainerImage), 0644); err != nil {
+			return nil, err
+		}
+		return files, createFile(files[2], SetupScript(state), 0755)
+	case SetupExportContributing:
+		path := filepath.Join(projectDir, "CONTRIBUTING.md")
+		return []string{path}, appendToFile(path, SetupContributingSection(state))
+	default:
+		return nil, fmt.Errorf("unknown export format `%s`. Use %s", format, strings.Join(SetupExportFormats, ", "))
+	}
+}
+
+// SetupScript renders the steps of state as a bash script. Steps with a check only run if the check fails.
+func SetupScript(state *SetupState) string {
+	var builder strings.Builder
+	builder.WriteString("#!/usr/bin/env bash\n")
+	fmt.Fprintf(&builder, "# Setup of this %s project, exported by `idk setup --export`\n", state.ProjectType)
+	builder.WriteString("set -euo pipefail\n")
+	for i, step := range state.Steps {
+		builder.WriteString("\n")
+		writeStepComment(&builder, "# ", i, step)
+		if step.Check != "" {
+			fmt.Fprintf(&builder, "if ! (%s) >/dev/null 2>&1; then\n  %s\nfi\n", step.Check, step.Command)
+		} else {
+			builder.WriteString(step.Command + "\n")
+		}
+	}
+	if state.RunCommand != "" {
+		fmt.Fprintf(&builder, "\necho %s\n", ShellQuote("Setup completed. Run the project with: "+state.RunCommand))
+	}
+	return builder.String()
+}
+
+// SetupMakefileTargets renders the steps of state as a setup target and the run command as a run target
+func SetupMakefileTargets(state *SetupState, withRun bool) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "\n# Setup of this %s project, exported by `idk setup --export`\n", state.ProjectType)
+	builder.WriteString(".PHONY: setup\nsetup:\n")
+	for i, step := range state.Steps {
+		// comments at the start of the line are make comments, tab indented ones would be echoed
+		writeStepComment(&builder, "# ", i, step)
+		command := makeEscape(step.Command)
+		if step.Check != "" {
+			command = fmt.Sprintf("if ! (%s) >/dev/null 2>&1; then %s; fi", makeEscape(step.Check), command)
+		}
+		builder.WriteString("\t" + command + "\n")
+	}
+	if withRun && state.RunCommand != "" {
+		fmt.Fprintf(&builder, "\n.PHONY: run\nrun:\n\t%s\n", makeEscape(state.RunCommand))
+	}
+	return builder.String()
+}
+
+// SetupDevcontainerJson renders a devcontainer.json that runs the exported setup.sh once the container is created
+func SetupDevcontainerJson(name string) (string, error) {
+	devcontainer := map[string]any{
+		"name":              name,
+		"build":             map[string]string{"dockerfile": "Dockerfile"},
+		"postCreateCommand": "bash .devcontainer/setup.sh",
+	}
+	data, err := json.MarshalIndent(devcontainer, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// SetupContributingSection renders the steps of state as a markdown section for CONTRIBUTING.md
+func SetupContributingSection(state *SetupState) string {
+	var builder strings.Builder
+	builder.WriteString("\n## Development setup\n\n")
+	fmt.Fprintf(&builder, "Run these commands to setup this %s project:\n", state.ProjectType)
+	for i, step := range state.Steps {
+		fmt.Fprintf(&builder, "\n%d. %s\n\n   ```sh\n   %s\n   ```\n", i+1, step.Description, strings.ReplaceAll(step.Command, "\n", "\n   "))
+		if step.Check != "" {
+			fmt.Fprintf(&builder, "\n   Skip this step if `%s` succeeds.\n", step.Check)
+		}
+	}
+	if state.RunCommand != "" {
+		fmt.Fprintf(&builder, "\nRun the project with:\n\n```sh\n%s\n```\n", state.RunCommand)
+	}
+	return builder.String()
+}
+
+func writeStepComment(builder *strings.Builder, prefix string, i int, step SetupStep) {
+	comment := fmt.Sprintf("%d. %s", i+1, step.Description)
+	if step.Status == SetupStepSkipped {
+		comment += " (skipped during the interactive setup)"
+	}
+	for _, line := range strings.Split(comment, "\n") {
+		builder.WriteString(prefix + line + "\n")
+	}
+}
+
+// makeEscape escapes $ in a shell command for a Makefile recipe
+func makeEscape(command string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(command, "$", "$$"), "\n", " \\\n\t")
+}
+
+// exportMakefile adds a setup target to the Makefile of the project, and a run target if there is none
+func exportMakefile(projectDir string, state *SetupState) ([]string, error) {
+	path := filepath.Join(projectDir, "Makefile")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	targets := ParseMakeTargets(string(data))
+	hasRun := false
+	for _, target := range targets {
+		if target == "setup" {
+			return nil, fmt.Errorf("%s already has a setup target", path)
+		}
+		hasRun = hasRun || target == "run"
+	}
+	return []string{path}, appendToFile(path, SetupMakefileTargets(state, !hasRun))
+}
+
+// createFile writes a new file and fails if it already exists
+func createFile(path string, content string, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(content)
+	return err
+}
+
+// appendToFile appends content to path, creating it if needed
+func appendToFile(path string, content string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.WriteString(content)
+	return err
+}
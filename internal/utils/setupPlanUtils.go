@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// HasDependencies checks if the steps of state declare dependencies or may run in parallel.
+// Steps without either run one after the other.
+func (s *SetupState) HasDependencies() bool {
+	for _, step := range s.Steps {
+		if len(step.DependsOn) > 0 || step.Parallel {
+			return true
+		}
+	}
+	return false
+}
+
+// StepId returns the id of step i, steps without an id are numbered from 1
+func (s *SetupState) StepId(i int) string {
+	if s.Steps[i].Id != "" {
+		return s.Steps[i].Id
+	}
+	return strconv.Itoa(i + 1)
+}
+
+// IsStepFinished checks if a step no longer has to run, which also makes the steps depending on it ready
+func IsStepFinished(step SetupStep) bool {
+	return step.Status == SetupStepDone || step.Status == SetupStepSatisfied || step.Status == SetupStepSkipped
+}
+
+// StepDependencies returns the indexes of the steps step i depends on
+func (s *SetupState) StepDependencies(i int) []int {
+	var dependencies []int
+	for _, id := range s.Steps[i].DependsOn {
+		for j := range s.Steps {
+			if s.StepId(j) == id {
+				dependencies = append(dependencies, j)
+			}
+		}
+	}
+	return dependencies
+}
+
+// ValidateSetupPlan checks that every dependency exists and that dependencies don't form a cycle
+func ValidateSetupPlan(state *SetupState) error {
+	ids := map[string]bool{}
+	for i := range state.Steps {
+		id := state.StepId(i)
+		if ids[id] {
+			return fmt.Errorf("step id `%s` is used twice", id)
+		}
+		ids[id] = true
+	}
+	for i, step := range state.Steps {
+		for _, id := range step.DependsOn {
+			if !ids[id] {
+				return fmt.Errorf("step %s depends on unknown step `%s`", state.StepId(i), id)
+			}
+		}
+	}
+
+	// 0 is unvisited, 1 is on the current path and 2 is checked
+	visits := make([]int, len(state.Steps))
+	var visit func(i int) error
+	visit = func(i int) error {
+		if visits[i] == 1 {
+			return fmt.Errorf("steps depend on each other in a cycle through step %s", state.StepId(i))
+		}
+		if visits[i] == 2 {
+			return nil
+		}
+		visits[i] = 1
+		for _, dependency := range state.StepDependencies(i) {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		visits[i] = 2
+		return nil
+	}
+	for i := range state.Steps {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outputMutex keeps the lines of commands running at the same time from mixing
+var outputMutex sync.Mutex
+
+// prefixWriter writes complete lines to out, each starting with prefix
+type prefixWriter struct {
+	out     io.Writer
+	prefix  string
+	pending []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		index := bytes.IndexByte(w.pending, '\n')
+		if index < 0 {
+			return len(p), nil
+		}
+		w.writeLine(w.pending[:index+1])
+		w.pending = w.pending[index+1:]
+	}
+}
+
+// Flush writes a last line that doesn't end with a line break
+func (w *prefixWriter) Flush() {
+	if len(w.pending) > 0 {
+		w.writeLine(append(w.pending, '\n'))
+		w.pending = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	_, _ = w.out.Write(append([]byte(w.prefix), line...))
+}
+
+// RunCommandWithPrefix runs a command without input, starting every line of its output with prefix so it can run
+// next to other commands. It returns the SHA-256 of the standard output like RunCommandWithOutputHash.
+func RunCommandWithPrefix(commandStr string, prefix string) (string, error) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		return "", fmt.Errorf("Unsupported platform")
+	}
+
+	hash := sha256.New()
+	stdout := &prefixWriter{out: os.Stdout, prefix: prefix}
+	stderr := &prefixWriter{out: os.Stderr, prefix: prefix}
+	defer stdout.Flush()
+	defer stderr.Flush()
+
+	cmd := exec.Command("/bin/sh", "-c", commandStr)
+	cmd.Stdout = io.MultiWriter(stdout, hash)
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	return hex.EncodeToString(hash.Sum(nil)), err
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Results of the verification of a setup
+const (
+	VerifyPassed  = "passed"
+	VerifyFailed  = "failed"
+	VerifySkipped = "skipped"
+)
+
+// reportTools are the tools whose versions are listed in setup reports if they are installed
+var reportTools = []string{
+	"git", "make", "docker", "go", "node", "npm", "pnpm", "yarn", "bun", "python3", "pip3", "poetry",
+	"cargo", "rustc", "ruby", "bundle", "java", "mvn", "gradle", "php", "composer", "elixir",
+}
+
+// toolVersionArgs are the version flags of tools that don't understand --version
+var toolVersionArgs = map[string][]string{
+	"go":     {"version"},
+	"java":   {"-version"},
+	"elixir": {"--short-version"},
+}
+
+// SetupVerification is the result of running the build, test or run command of a setup
+type SetupVerification struct {
+	Name     string        `json:"name"`
+	Command  string        `json:"command"`
+	Status   string        `json:"status"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// SetupReport summarizes a setup for onboarding tickets: the tools on the machine, the result of each step
+// and of the verification
+type SetupReport struct {
+	ProjectType  string              `json:"projectType"`
+	Os           string              `json:"os"`
+	Arch         string              `json:"arch"`
+	Date         time.Time           `json:"date"`
+	Tools        []ToolVersion       `json:"tools"`
+	Steps        []SetupStep         `json:"steps"`
+	Verification []SetupVerification `json:"verification"`
+}
+
+type ToolVersion struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// DefaultVerifyCommands guesses the build and test commands of the root project of scan from its build system
+func DefaultVerifyCommands(scan *ProjectScan) (string, string) {
+	var root *ScannedProject
+	for i := range scan.Projects {
+		if scan.Projects[i].Path == "." {
+			root = &scan.Projects[i]
+		}
+	}
+	if root == nil {
+		return "", ""
+	}
+
+	hasTarget := func(name string) bool {
+		for _, target := range root.MakeTargets {
+			if target == name {
+				return true
+			}
+		}
+		return false
+	}
+	switch root.BuildSystem {
+	case "go":
+		return "go build ./...", "go test ./..."
+	case "cargo":
+		return "cargo build", "cargo test"
+	case "npm", "pnpm", "yarn":
+		build, test := "", ""
+		if root.PackageScripts["build"] != "" {
+			build = root.BuildSystem + " run build"
+		}
+		if root.PackageScripts["test"] != "" {
+			test = root.BuildSystem + " test"
+		}
+		return build, test
+	case "maven":
+		return "mvn -q compile", "mvn -q test"
+	case "gradle":
+		return "./gradlew build -x test", "./gradlew test"
+	case "make":
+		build, test := "", ""
+		if hasTarget("build") {
+			build = "make build"
+		}
+		if hasTarget("test") {
+			test = "make test"
+		}
+		return build, test
+	}
+	return "", ""
+}
+
+// InstalledToolVersions returns the versions of the common development tools installed on this machine
+func InstalledToolVersions() []ToolVersion {
+	var tools []ToolVersion
+	for _, tool := range reportTools {
+		if _, err := exec.LookPath(tool); err != nil {
+			continue
+		}
+		tools = append(tools, ToolVersion{Name: tool, Version: toolVersion(tool)})
+	}
+	return tools
+}
+
+// toolVersion returns the first line of the version output of tool
+func toolVersion(tool string) string {
+	args, ok := toolVersionArgs[tool]
+	if !ok {
+		args = []string{"--version"}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, tool, args...).CombinedOutput()
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	if err != nil || line == "" {
+		return "unknown"
+	}
+	return strings.TrimSpace(line)
+}
+
+// NewSetupReport collects the report of a setup and its verification
+func NewSetupReport(state *SetupState, verification []SetupVerification) *SetupReport {
+	return &SetupReport{
+		ProjectType:  state.ProjectType,
+		Os:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Date:         time.Now(),
+		Tools:        InstalledToolVersions(),
+		Steps:        state.Steps,
+		Verification: verification,
+	}
+}
+
+// Markdown renders the report so it can be pasted into tickets
+func (r *SetupReport) Markdown() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# Setup report\n\n%s project on %s/%s, %s\n", r.ProjectType, r.Os, r.Arch, r.Date.Format(time.RFC1123))
+
+	builder.WriteString("\n## Tools\n\n")
+	for _, tool := range r.Tools {
+		fmt.Fprintf(&builder, "- %s: %s\n", tool.Name, tool.Version)
+	}
+
+	builder.WriteString("\n## Steps\n\n")
+	for i, step := range r.Steps {
+		fmt.Fprintf(&builder, "%d. %s: %s (`%s`)\n", i+1, step.Description, step.Status, step.Command)
+		if step.Error != "" {
+			fmt.Fprintf(&builder, "   Error: %s\n", step.Error)
+		}
+	}
+
+	builder.WriteString("\n## Verification\n\n")
+	for _, check := range r.Verification {
+		fmt.Fprintf(&builder, "- %s: %s", check.Name, check.Status)
+		if check.Command != "" {
+			fmt.Fprintf(&builder, " (`%s`", check.Command)
+			if check.Status != VerifySkipped {
+				fmt.Fprintf(&builder, " in %s", check.Duration.Round(time.Millisecond))
+			}
+			builder.WriteString(")")
+		}
+		builder.WriteString("\n")
+		if check.Error != "" {
+			fmt.Fprintf(&builder, "  Error: %s\n", check.Error)
+		}
+	}
+	return builder.String()
+}
+
+// SaveSetupReport writes the report next to the setup state and returns its path
+func SaveSetupReport(projectDir string, report *SetupReport) (string, error) {
+	path := filepath.Join(filepath.Dir(setupStatePath(projectDir)), "setup-report.md")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, []byte(report.Markdown()), 0644)
+}
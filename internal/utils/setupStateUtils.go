@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Statuses of a setup step
+const (
+	SetupStepPending = "pending"
+	SetupStepDone    = "done"
+	// SetupStepSatisfied steps were skipped because their check command passed
+	SetupStepSatisfied = "satisfied"
+	SetupStepSkipped   = "skipped"
+	SetupStepFailed    = "failed"
+)
+
+// SetupState is the progress of `idk setup` in a project, kept in .idk/setup-state so a setup can be resumed
+type SetupState struct {
+	ProjectType string      `json:"projectType"`
+	Steps       []SetupStep `json:"steps"`
+	// RunCommand runs the project once it is setup
+	RunCommand string `json:"runCommand"`
+	// BuildCommand, TestCommand, ReadyPort and ReadyLog verify the setup, see RunVerifyCommand
+	BuildCommand string    `json:"buildCommand,omitempty"`
+	TestCommand  string    `json:"testCommand,omitempty"`
+	ReadyPort    int       `json:"readyPort,omitempty"`
+	ReadyLog     string    `json:"readyLog,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+type SetupStep struct {
+	// Id names the step for DependsOn, steps without one are numbered from 1
+	Id          string `json:"id,omitempty"`
+	Command     string `json:"command"`
+	Description string `json:"description"`
+	// DependsOn are the ids of the steps that have to finish before this one
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Parallel steps run next to other parallel steps, the others run alone and can read the terminal
+	Parallel bool `json:"parallel,omitempty"`
+	// Check is a command that succeeds if the step is already satisfied
+	Check  string `json:"check,omitempty"`
+	Status string `json:"status"`
+	// OutputHash is the SHA-256 of the output of the last run, to tell if reruns changed anything
+	OutputHash string `json:"outputHash,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NextStep returns the index of the first step that still has to run, or len(Steps) if the setup is complete
+func (s *SetupState) NextStep() int {
+	for i, step := range s.Steps {
+		if step.Status == SetupStepPending || step.Status == SetupStepFailed {
+			return i
+		}
+	}
+	return len(s.Steps)
+}
+
+// IsComplete checks if every step is done, satisfied or skipped
+func (s *SetupState) IsComplete() bool {
+	return s.NextStep() == len(s.Steps)
+}
+
+func setupStatePath(projectDir string) string {
+	return filepath.Join(projectDir, ".idk", "setup-state")
+}
+
+// LoadSetupState loads the setup progress of the project in projectDir, or returns nil if it was never setup
+func LoadSetupState(projectDir string) (*SetupState, error) {
+	data, err := os.ReadFile(setupStatePath(projectDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state SetupState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SaveSetupState writes the setup progress of the project in projectDir. The .idk directory
+// ignores itself so the state doesn't show up in git.
+func SaveSetupState(projectDir string, state *SetupState) error {
+	dir := filepath.Dir(setupStatePath(projectDir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	gitIgnorePath := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(gitIgnorePath); os.IsNotExist(err) {
+		if err := os.WriteFile(gitIgnorePath, []byte("*\n"), 0644); err != nil {
+			return err
+		}
+	}
+
+	state.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(setupStatePath(projectDir), data, 0644)
+}
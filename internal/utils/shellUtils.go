@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// shellOperators separate simple commands, redirectOperators take the next word as a file
+var (
+	shellOperators    = map[string]bool{"&&": true, "||": true, ";": true, "|": true, "&": true, "\n": true}
+	redirectOperators = map[string]bool{">": true, ">>": true, ">|": true, "&>": true, "&>>": true, "2>": true, "2>>": true, "1>": true, "1>>": true}
+)
+
+// SplitShellWords splits a shell command line into words and operators. Quotes and backslash
+// escapes are resolved, variables and globs are left as they are.
+func SplitShellWords(command string) []string {
+	var words []string
+	var current strings.Builder
+	inWord := false
+	flush := func() {
+		if inWord {
+			words = append(words, current.String())
+			current.Reset()
+			inWord = false
+		}
+	}
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '\''; i++ {
+				current.WriteRune(runes[i])
+			}
+		case r == '"':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				current.WriteRune(runes[i])
+			}
+		case r == '\\' && i+1 < len(runes):
+			i++
+			if runes[i] != '\n' {
+				inWord = true
+				current.WriteRune(runes[i])
+			}
+		case r == '#' && !inWord:
+			// comment until the end of the line
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case r == ' ' || r == '\t':
+			flush()
+		case strings.ContainsRune("&|;<>\n", r):
+			// a file descriptor number directly before a redirect belongs to the operator
+			operator := ""
+			if (r == '>' || r == '<') && inWord && (current.String() == "1" || current.String() == "2") {
+				operator = current.String()
+				current.Reset()
+				inWord = false
+			}
+			flush()
+			operator += string(r)
+			for i+1 < len(runes) && strings.ContainsRune("&|>", runes[i+1]) && len(operator) < 3 {
+				if r == ';' || r == '\n' {
+					break
+				}
+				i++
+				operator += string(runes[i])
+			}
+			words = append(words, operator)
+		default:
+			inWord = true
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return words
+}
+
+// SplitShellCommands splits a command line into simple commands (lists of words) and the
+// files written by redirects
+func SplitShellCommands(command string) ([][]string, []string) {
+	var commands [][]string
+	var redirectTargets []string
+	var current []string
+
+	words := SplitShellWords(command)
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		switch {
+		case shellOperators[word]:
+			if len(current) > 0 {
+				commands = append(commands, current)
+			}
+			current = nil
+		case redirectOperators[word]:
+			if i+1 < len(words) {
+				redirectTargets = append(redirectTargets, words[i+1])
+				i++
+			}
+		case word == "<" || word == "<<" || strings.HasSuffix(word, ">&"):
+			// input redirects and fd duplication don't write files
+			i++
+		default:
+			current = append(current, word)
+		}
+	}
+	if len(current) > 0 {
+		commands = append(commands, current)
+	}
+	return commands, redirectTargets
+}
+
+// ModifiedPaths guesses which files a shell command changes: targets of mv, rm, cp, sed -i,
+// chmod and similar commands plus files written by redirects. Paths are made absolute relative
+// to dir and globs are expanded. Paths that can't be known up front (variables, subshells) are skipped.
+func ModifiedPaths(command string, dir string) []string {
+	commands, paths := SplitShellCommands(command)
+
+	for _, words := range commands {
+		// skip wrappers that run the actual command
+		for len(words) > 0 && (words[0] == "sudo" || words[0] == "command" || words[0] == "env" || strings.Contains(words[0], "=")) {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			continue
+		}
+
+		args := nonFlagArgs(words[1:])
+		switch filepath.Base(words[0]) {
+		case "rm", "rmdir", "unlink", "shred", "truncate", "touch", "tee", "mv":
+			paths = append(paths, args...)
+		case "cp", "ln", "install", "rsync":
+			if len(args) > 0 {
+				paths = append(paths, args[len(args)-1])
+			}
+		case "chmod", "chown", "chgrp":
+			// the first argument is the mode or owner
+			if len(args) > 1 {
+				paths = append(paths, args[1:]...)
+			}
+		case "sed", "perl":
+			if !hasInPlaceFlag(words[1:]) {
+				continue
+			}
+			paths = append(paths, sedFileArgs(words[1:])...)
+		}
+	}
+
+	var absolutePaths []string
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if path == "" || strings.ContainsAny(path, "$`") || strings.HasPrefix(path, "/dev/") {
+			continue
+		}
+		if strings.HasPrefix(path, "~/") {
+			path = GetAbsoluteHomeDirectoryPath([]string{path[2:]})
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		matches := []string{path}
+		if strings.ContainsAny(path, "*?[") {
+			matches, _ = filepath.Glob(path)
+		}
+		for _, match := range matches {
+			match = filepath.Clean(match)
+			if !seen[match] {
+				seen[match] = true
+				absolutePaths = append(absolutePaths, match)
+			}
+		}
+	}
+	return absolutePaths
+}
+
+func nonFlagArgs(words []string) []string {
+	var args []string
+	endOfFlags := false
+	for _, word := range words {
+		if word == "--" && !endOfFlags {
+			endOfFlags = true
+			continue
+		}
+		if !endOfFlags && strings.HasPrefix(word, "-") && word != "-" {
+			continue
+		}
+		args = append(args, word)
+	}
+	return args
+}
+
+func hasInPlaceFlag(words []string) bool {
+	for _, word := range words {
+		if word == "--in-place" || strings.HasPrefix(word, "--in-place=") {
+			return true
+		}
+		if strings.HasPrefix(word, "-") && !strings.HasPrefix(word, "--") && strings.Contains(word, "i") {
+			return true
+		}
+	}
+	return false
+}
+
+// sedFileArgs returns the files of a sed or perl command line, skipping its expressions
+func sedFileArgs(words []string) []string {
+	var args []string
+	hasExpressionFlag := false
+	for i := 0; i < len(words); i++ {
+		switch {
+		case words[i] == "-e" || words[i] == "-f" || words[i] == "--expression" || words[i] == "--file":
+			hasExpressionFlag = true
+			i++
+		case strings.HasPrefix(words[i], "-"):
+			continue
+		default:
+			args = append(args, words[i])
+		}
+	}
+
+	// without -e the first argument is the expression
+	if !hasExpressionFlag && len(args) > 0 {
+		args = args[1:]
+	}
+	return args
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// snapshotBudget is the maximum number of bytes copied into one snapshot
+	snapshotBudget = 200 * 1024 * 1024
+	// maxSnapshots is how many snapshots are kept before the oldest are removed
+	maxSnapshots = 50
+)
+
+// Snapshot holds copies of the files a command was about to modify, so the command can be undone
+type Snapshot struct {
+	// Id is the id of the history entry the snapshot belongs to
+	Id         string         `json:"id"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	Dir        string         `json:"dir"`
+	Command    string         `json:"command"`
+	Files      []SnapshotFile `json:"files"`
+	RestoredAt *time.Time     `json:"restoredAt,omitempty"`
+}
+
+// SnapshotFile is one path saved in a snapshot
+type SnapshotFile struct {
+	Path string `json:"path"`
+	// Existed is false for paths the command was about to create, undo removes them
+	Existed bool `json:"existed"`
+	// Stored is the name of the copy inside the snapshot directory
+	Stored string `json:"stored,omitempty"`
+}
+
+func snapshotsDir() string {
+	return GetAbsoluteHomeDirectoryPath([]string{".idk", "snapshots"})
+}
+
+// SkippedPath is a path TakeSnapshot left out, so undo can't restore it
+type SkippedPath struct {
+	Path string
+	// Reason completes a sentence about the path, like "is too large to snapshot"
+	Reason string
+}
+
+// TakeSnapshot copies paths into ~/.idk/snapshots/<id>. Paths that don't fit in the size
+// budget or can't be copied are left out and returned as skipped.
+func TakeSnapshot(id string, command string, paths []string) (*Snapshot, []SkippedPath, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapshot := &Snapshot{
+		Id:        id,
+		CreatedAt: time.Now(),
+		Dir:       dir,
+		Command:   command,
+	}
+	snapshotDir := filepath.Join(snapshotsDir(), id)
+	if err := os.MkdirAll(filepath.Join(snapshotDir, "files"), 0700); err != nil {
+		return nil, nil, err
+	}
+
+	var skipped []SkippedPath
+	var totalSize int64
+	for i, path := range paths {
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			snapshot.Files = append(snapshot.Files, SnapshotFile{Path: path, Existed: false})
+			continue
+		}
+		if err != nil {
+			skipped = append(skipped, SkippedPath{Path: path, Reason: fmt.Sprintf("could not be read (%s)", err)})
+			continue
+		}
+
+		size, fits := treeSize(path, info, snapshotBudget-totalSize)
+		if !fits {
+			skipped = append(skipped, SkippedPath{Path: path, Reason: "is too large to snapshot"})
+			continue
+		}
+		totalSize += size
+
+		stored := strconv.Itoa(i)
+		storedPath := filepath.Join(snapshotDir, "files", stored)
+		if err := saveSnapshotPath(path, info, storedPath); err != nil {
+			os.RemoveAll(storedPath)
+			skipped = append(skipped, SkippedPath{Path: path, Reason: fmt.Sprintf("could not be copied (%s)", err)})
+			continue
+		}
+		snapshot.Files = append(snapshot.Files, SnapshotFile{Path: path, Existed: true, Stored: stored})
+	}
+
+	if err := saveSnapshotManifest(snapshot); err != nil {
+		return nil, nil, err
+	}
+	pruneSnapshots()
+
+	return snapshot, skipped, nil
+}
+
+func saveSnapshotPath(path string, info fs.FileInfo, storedPath string) error {
+	switch {
+	case info.IsDir():
+		return CopyTree(path, storedPath)
+	case info.Mode().IsRegular():
+		return BackupFile(path, storedPath)
+	default:
+		return copyEntry(path, storedPath, info.Mode().Type())
+	}
+}
+
+// treeSize returns the size of the files at path and whether it is within limit.
+// The walk stops as soon as the limit is exceeded.
+func treeSize(path string, info fs.FileInfo, limit int64) (int64, bool) {
+	if !info.IsDir() {
+		return info.Size(), info.Size() <= limit
+	}
+
+	var size int64
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entryInfo, err := entry.Info(); err == nil && entryInfo.Mode().IsRegular() {
+			size += entryInfo.Size()
+		}
+		if size > limit {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return size, size <= limit
+}
+
+func saveSnapshotManifest(snapshot *Snapshot) error {
+	bytes, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(snapshotsDir(), snapshot.Id, "manifest.json"), bytes, 0600)
+}
+
+// LoadSnapshot reads the manifest of the snapshot with the given id
+func LoadSnapshot(id string) (*Snapshot, error) {
+	// the id ends up in a path, anything but a history id could point outside the snapshots
+	if !IsHistoryId(id) {
+		return nil, fmt.Errorf("invalid snapshot id %q", id)
+	}
+	bytes, err := os.ReadFile(filepath.Join(snapshotsDir(), id, "manifest.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(bytes, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// ListSnapshots returns all snapshots, newest first
+func ListSnapshots() ([]Snapshot, error) {
+	entries, err := os.ReadDir(snapshotsDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshot, err := LoadSnapshot(entry.Name())
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// RestoreSnapshot puts every saved path back the way it was and removes paths the command created.
+// Nothing is touched unless every saved copy is still there.
+func RestoreSnapshot(snapshot *Snapshot) error {
+	snapshotDir := filepath.Join(snapshotsDir(), snapshot.Id)
+
+	stored := make([]fs.FileInfo, len(snapshot.Files))
+	for i, file := range snapshot.Files {
+		if !file.Existed {
+			continue
+		}
+		info, err := os.Lstat(filepath.Join(snapshotDir, "files", file.Stored))
+		if err != nil {
+			return fmt.Errorf("snapshot copy of %s is missing: %w", file.Path, err)
+		}
+		stored[i] = info
+	}
+
+	for i, file := range snapshot.Files {
+		if err := os.RemoveAll(file.Path); err != nil {
+			return err
+		}
+		if !file.Existed {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(file.Path), 0755); err != nil {
+			return err
+		}
+		if err := saveSnapshotPath(filepath.Join(snapshotDir, "files", file.Stored), stored[i], file.Path); err != nil {
+			return err
+		}
+	}
+
+	restoredAt := time.Now()
+	snapshot.RestoredAt = &restoredAt
+	return saveSnapshotManifest(snapshot)
+}
+
+// pruneSnapshots removes the oldest snapshots beyond maxSnapshots
+func pruneSnapshots() {
+	snapshots, err := ListSnapshots()
+	if err != nil || len(snapshots) <= maxSnapshots {
+		return
+	}
+	for _, snapshot := range snapshots[maxSnapshots:] {
+		os.RemoveAll(filepath.Join(snapshotsDir(), snapshot.Id))
+	}
+}
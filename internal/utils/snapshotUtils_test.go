@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRestoreSnapshot(t *testing.T) {
+	tests := []struct {
+		name string
+		// change runs between the snapshot and the restore, like the command would
+		change  func(t *testing.T, dir string, snapshot *Snapshot)
+		wantErr bool
+		// want are the contents of the files after the restore, "" means the file must not exist
+		want map[string]string
+	}{
+		{
+			name: "restores modified and deleted files",
+			change: func(t *testing.T, dir string, snapshot *Snapshot) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "changed")
+				if err := os.RemoveAll(filepath.Join(dir, "tree")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: map[string]string{"file.txt": "original", "tree/nested.txt": "nested"},
+		},
+		{
+			name: "removes created files",
+			change: func(t *testing.T, dir string, snapshot *Snapshot) {
+				writeTestFile(t, filepath.Join(dir, "created.txt"), "new")
+			},
+			want: map[string]string{"file.txt": "original", "created.txt": ""},
+		},
+		{
+			name: "keeps the files if a copy is missing",
+			change: func(t *testing.T, dir string, snapshot *Snapshot) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "changed")
+				writeTestFile(t, filepath.Join(dir, "created.txt"), "new")
+				for _, file := range snapshot.Files {
+					if filepath.Base(file.Path) == "tree" {
+						if err := os.RemoveAll(filepath.Join(snapshotsDir(), snapshot.Id, "files", file.Stored)); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+			},
+			wantErr: true,
+			want:    map[string]string{"file.txt": "changed", "created.txt": "new", "tree/nested.txt": "nested"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, "file.txt"), "original")
+			writeTestFile(t, filepath.Join(dir, "tree", "nested.txt"), "nested")
+			paths := []string{filepath.Join(dir, "file.txt"), filepath.Join(dir, "tree"), filepath.Join(dir, "created.txt")}
+
+			snapshot, skipped, err := TakeSnapshot("test", "command", paths)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(skipped) > 0 {
+				t.Fatalf("skipped %v", skipped)
+			}
+			test.change(t, dir, snapshot)
+
+			err = RestoreSnapshot(snapshot)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("RestoreSnapshot() error = %v, wantErr %v", err, test.wantErr)
+			}
+			for file, want := range test.want {
+				data, err := os.ReadFile(filepath.Join(dir, file))
+				if want == "" {
+					if !os.IsNotExist(err) {
+						t.Errorf("%s exists after the restore", file)
+					}
+					continue
+				}
+				if err != nil || string(data) != want {
+					t.Errorf("%s = %q, %v, want %q", file, data, err, want)
+				}
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	id := NewHistoryId()
+	writeTestFile(t, filepath.Join(snapshotsDir(), id, "manifest.json"), `{"id":"`+id+`"}`)
+	// a manifest outside the snapshots directory, which ids must not reach
+	writeTestFile(t, filepath.Join(os.Getenv("HOME"), ".idk", "elsewhere", "manifest.json"), `{"id":"elsewhere"}`)
+
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{id, false},
+		{"../elsewhere", true},
+		{"../../" + filepath.Base(os.Getenv("HOME")) + "/.idk/elsewhere", true},
+		{"/tmp", true},
+		{"", true},
+		{"20240102-150405-a1B2", true},
+	}
+
+	for _, test := range tests {
+		snapshot, err := LoadSnapshot(test.id)
+		if (err != nil) != test.wantErr {
+			t.Errorf("LoadSnapshot(%q) = %v, %v, wantErr %v", test.id, snapshot, err, test.wantErr)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package utils
 
 import (
 	"crypto/rand"
+	"encoding/json"
+	"fmt"
 	"regexp"
 	"sort"
 
@@ -50,16 +52,12 @@ func FindMostRelevantStringFromArr(arr []string, s string) string {
 	return sortedMatches[0]
 }
 
-func PrintMessage(message string) {
-	println("-----------------------------------")
-	println(message)
-	println("-----------------------------------")
-}
-
-func PrintMessages(messages []string) {
-	println("-----------------------------------")
-	for _, message := range messages {
-		println(message)
+// PrintJson writes v to stdout as indented JSON
+func PrintJson(v interface{}) {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		fmt.Println(`{"error": "internal_error"}`)
+		return
 	}
-	println("-----------------------------------")
+	fmt.Println(string(bytes))
 }
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	templatePlaceholderRegex = regexp.MustCompile(`\{\{\s*([a-zA-Z_][a-zA-Z0-9_\-]*)\s*\}\}`)
+	shellSafeRegex           = regexp.MustCompile(`^[a-zA-Z0-9_@%+=:,./\-]+$`)
+)
+
+// TemplatePlaceholders returns the names of the {{key}} placeholders in text, in order of appearance
+func TemplatePlaceholders(text string) []string {
+	var names []string
+	for _, match := range templatePlaceholderRegex.FindAllStringSubmatch(text, -1) {
+		if !slices.Contains(names, match[1]) {
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// ParseTemplateArgs turns `key=value` arguments into template values
+func ParseTemplateArgs(args []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, templateArg := range args {
+		key, value, ok := strings.Cut(templateArg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid template value `%s`, expected key=value", templateArg)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// ExpandTemplate replaces the {{key}} placeholders in text with values, passing each value
+// through quote first when it is not nil
+func ExpandTemplate(text string, values map[string]string, quote func(string) string) (string, error) {
+	var missing []string
+	for _, name := range TemplatePlaceholders(text) {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing template values: %s", strings.Join(missing, ", "))
+	}
+
+	return templatePlaceholderRegex.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value := values[templatePlaceholderRegex.FindStringSubmatch(placeholder)[1]]
+		if quote != nil {
+			return quote(value)
+		}
+		return value
+	}), nil
+}
+
+// ShellQuote quotes s so the shell reads it as a single word
+func ShellQuote(s string) string {
+	if shellSafeRegex.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
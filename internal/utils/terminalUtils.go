@@ -1,13 +1,23 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
+
+	"golang.org/x/term"
 )
 
 func RunCommand(commandStr string) error {
+	return RunCommandWithOutput(commandStr, os.Stdout)
+}
+
+// RunCommandWithOutput runs a command like RunCommand but sends its standard output to stdout
+func RunCommandWithOutput(commandStr string, stdout io.Writer) error {
 	var cmd *exec.Cmd
 
 	// Check the operating system
@@ -19,7 +29,7 @@ func RunCommand(commandStr string) error {
 	}
 
 	cmd.Stdin = os.Stdin   // Connect the command's standard input to the os Stdin
-	cmd.Stdout = os.Stdout // Connect the command's standard output to the os Stdout
+	cmd.Stdout = stdout    // Connect the command's standard output to the given writer
 	cmd.Stderr = os.Stderr // Connect the command's standard error to the os Stderr
 
 	// Start the command and wait for it to finish
@@ -29,35 +39,26 @@ func RunCommand(commandStr string) error {
 	return cmd.Wait()
 }
 
-func IsBrewInstalled() bool {
-	cmd := exec.Command("brew", "--version")
-	if err := cmd.Run(); err != nil {
-		return false
-	}
-	return true
+// RunCommandWithOutputHash runs a command like RunCommand and returns the SHA-256 of its standard output
+func RunCommandWithOutputHash(commandStr string) (string, error) {
+	hash := sha256.New()
+	err := RunCommandWithOutput(commandStr, io.MultiWriter(os.Stdout, hash))
+	return hex.EncodeToString(hash.Sum(nil)), err
 }
 
-func InstallBrew() error {
-	var cmd *exec.Cmd
-
-	// Determine the OS and set up the appropriate installation command
-	switch runtime.GOOS {
-	case "darwin":
-		// macOS installation command
-		cmd = exec.Command("/bin/bash", "-c", "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)")
-	case "linux":
-		// Linux installation command
-		cmd = exec.Command("/bin/bash", "-c", "$(curl -fsSL https://raw.githubusercontent.com/Homebrew/install/HEAD/install.sh)")
-	default:
-		return fmt.Errorf("Homebrew installation is not supported on your OS: %s", runtime.GOOS)
+// RunCommandOnTerminal runs a command like RunCommandWithOutputHash, but if stdout is a terminal the command gets it
+// directly, so prompts for passwords or licenses work like in a shell. There is no output hash then.
+func RunCommandOnTerminal(commandStr string) (string, error) {
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		return "", RunCommand(commandStr)
 	}
+	return RunCommandWithOutputHash(commandStr)
+}
 
-	// Run the installation command
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to install Homebrew: %s, %s", err, output)
+// CommandSucceeds runs a command without showing its output and checks if it exits with 0
+func CommandSucceeds(commandStr string) bool {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		return false
 	}
-
-	fmt.Println("Homebrew installed successfully")
-	return nil
+	return exec.Command("/bin/sh", "-c", commandStr).Run() == nil
 }
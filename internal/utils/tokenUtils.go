@@ -1,11 +1,14 @@
 package utils
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // TokenData wraps both access and refresh tokens
@@ -48,6 +51,37 @@ func LoadToken() (string, error) {
 	return data.JwtToken, nil
 }
 
+// TokenClaims are the claims of the idk JWT token that are shown to users
+type TokenClaims struct {
+	Email string `json:"email"`
+	// ExpiresAt is zero if the token has no expiry
+	ExpiresAt time.Time `json:"-"`
+}
+
+// ParseTokenClaims reads the claims of a JWT token without verifying its signature, only the backend can do that
+func ParseTokenClaims(jwtToken string) (*TokenClaims, error) {
+	parts := strings.Split(jwtToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		TokenClaims
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+	if claims.Exp > 0 {
+		claims.ExpiresAt = time.Unix(claims.Exp, 0)
+	}
+	return &claims.TokenClaims, nil
+}
+
 func ClearToken() error {
 	secretsFilePath := GetAbsoluteHomeDirectoryPath([]string{".idk", "credentials"})
 
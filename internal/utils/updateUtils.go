@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// UpdateState remembers the version kept next to the executable by the last update, for `idk self rollback`
+type UpdateState struct {
+	PreviousVersion string `json:"previousVersion"`
+}
+
+func updateStatePath() string {
+	return GetAbsoluteHomeDirectoryPath([]string{".idk", "update.json"})
+}
+
+func LoadUpdateState() UpdateState {
+	var state UpdateState
+	data, err := os.ReadFile(updateStatePath())
+	if err == nil {
+		_ = json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+func saveUpdateState(state UpdateState) error {
+	if err := os.MkdirAll(filepath.Dir(updateStatePath()), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(updateStatePath(), data, 0600)
+}
+
+// CompareVersions compares versions like 1.2.3 or v1.2.3-beta.1 and returns -1, 0 or 1.
+// Pre-releases are older than the release they lead up to.
+func CompareVersions(a string, b string) int {
+	aCore, aPre, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	bCore, bPre, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+
+	aParts := strings.Split(aCore, ".")
+	bParts := strings.Split(bCore, ".")
+	for i := 0; i < max(len(aParts), len(bParts)); i++ {
+		var aNumber, bNumber int
+		if i < len(aParts) {
+			aNumber, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bNumber, _ = strconv.Atoi(bParts[i])
+		}
+		if aNumber != bNumber {
+			if aNumber < bNumber {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// ReleaseSignedMessage returns what the release key signs for a binary: the version and platform of the
+// release together with the checksum of the binary. Signing the version keeps an old signed binary from being
+// served as a newer release.
+func ReleaseSignedMessage(version string, osName string, arch string, sha256Hex string) []byte {
+	return []byte(fmt.Sprintf("idk release %s %s/%s sha256:%s", strings.TrimPrefix(version, "v"), osName, arch, strings.ToLower(sha256Hex)))
+}
+
+// VerifyReleaseBinary checks the SHA-256 checksum of a downloaded binary and the ed25519 signature of
+// the release message that binds the checksum to version, osName and arch
+func VerifyReleaseBinary(data []byte, version string, osName string, arch string, sha256Hex string, signature string, publicKey string) error {
+	checksum := sha256.Sum256(data)
+	if !strings.EqualFold(hex.EncodeToString(checksum[:]), sha256Hex) {
+		return fmt.Errorf("checksum mismatch, the download is corrupted or was tampered with")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release public key")
+	}
+	signatureBytes, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid release signature")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), ReleaseSignedMessage(version, osName, arch, sha256Hex), signatureBytes) {
+		return fmt.Errorf("signature verification failed, the release was not signed by the idk release key for %s %s/%s", version, osName, arch)
+	}
+	return nil
+}
+
+// executablePath returns the path of the running idk binary with symlinks resolved
+func executablePath() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(executable)
+}
+
+func previousExecutablePath(executable string) string {
+	return executable + ".previous"
+}
+
+// writeExecutable writes data to a new executable file in dir and returns its path
+func writeExecutable(dir string, data []byte) (string, error) {
+	file, err := os.CreateTemp(dir, ".idk-update-*")
+	if err != nil {
+		return "", fmt.Errorf("can't write to %s, rerun with permission to change the idk install: %w", dir, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	if err := file.Chmod(0755); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// InstallExecutable atomically replaces the running executable with data. The replaced
+// executable is kept next to it as <name>.previous so the update can be rolled back.
+func InstallExecutable(data []byte, currentVersion string) error {
+	executable, err := executablePath()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(executable)
+
+	newPath, err := writeExecutable(dir, data)
+	if err != nil {
+		return err
+	}
+	if err := keepPreviousExecutable(executable); err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	if err := replaceExecutable(newPath, executable); err != nil {
+		os.Remove(newPath)
+		return err
+	}
+	return saveUpdateState(UpdateState{PreviousVersion: currentVersion})
+}
+
+// RollbackExecutable swaps the running executable with the one kept by the last update
+// and returns the version that is installed now. Rolling back twice undoes the rollback.
+func RollbackExecutable(currentVersion string) (string, error) {
+	executable, err := executablePath()
+	if err != nil {
+		return "", err
+	}
+	previous := previousExecutablePath(executable)
+	previousData, err := os.ReadFile(previous)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("there is no previous version to roll back to")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	newPath, err := writeExecutable(filepath.Dir(executable), previousData)
+	if err != nil {
+		return "", err
+	}
+	if err := keepPreviousExecutable(executable); err != nil {
+		os.Remove(newPath)
+		return "", err
+	}
+	if err := replaceExecutable(newPath, executable); err != nil {
+		os.Remove(newPath)
+		return "", err
+	}
+
+	state := LoadUpdateState()
+	if err := saveUpdateState(UpdateState{PreviousVersion: currentVersion}); err != nil {
+		return "", err
+	}
+	return state.PreviousVersion, nil
+}
+
+// keepPreviousExecutable copies executable to <executable>.previous
+func keepPreviousExecutable(executable string) error {
+	source, err := os.Open(executable)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	data, err := io.ReadAll(source)
+	if err != nil {
+		return err
+	}
+
+	copyPath, err := writeExecutable(filepath.Dir(executable), data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(copyPath, previousExecutablePath(executable)); err != nil {
+		os.Remove(copyPath)
+		return err
+	}
+	return nil
+}
+
+// replaceExecutable moves newPath over executable in one rename
+func replaceExecutable(newPath string, executable string) error {
+	if runtime.GOOS == "windows" {
+		// a running executable can't be overwritten on windows, but it can be moved away
+		oldPath := executable + ".old"
+		os.Remove(oldPath)
+		if err := os.Rename(executable, oldPath); err != nil {
+			return err
+		}
+		if err := os.Rename(newPath, executable); err != nil {
+			_ = os.Rename(oldPath, executable)
+			return err
+		}
+		return nil
+	}
+	return os.Rename(newPath, executable)
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestVerifyReleaseBinary(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublicKey, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksum := sha256.Sum256([]byte("idk 1.1.0"))
+	sha256Hex := hex.EncodeToString(checksum[:])
+	sign := func(version string, osName string, arch string) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, ReleaseSignedMessage(version, osName, arch, sha256Hex)))
+	}
+	key := base64.StdEncoding.EncodeToString(publicKey)
+
+	tests := []struct {
+		name      string
+		data      string
+		version   string
+		sha256Hex string
+		signature string
+		publicKey string
+		wantErr   bool
+	}{
+		{"valid", "idk 1.1.0", "1.1.0", sha256Hex, sign("1.1.0", "linux", "amd64"), key, false},
+		{"v prefix and upper case checksum", "idk 1.1.0", "v1.1.0", strings.ToUpper(sha256Hex), sign("1.1.0", "linux", "amd64"), key, false},
+		{"tampered binary", "idk 6.6.6", "1.1.0", sha256Hex, sign("1.1.0", "linux", "amd64"), key, true},
+		{"signature replayed for a newer version", "idk 1.1.0", "2.0.0", sha256Hex, sign("1.1.0", "linux", "amd64"), key, true},
+		{"signature of another platform", "idk 1.1.0", "1.1.0", sha256Hex, sign("1.1.0", "darwin", "arm64"), key, true},
+		{"other key", "idk 1.1.0", "1.1.0", sha256Hex, sign("1.1.0", "linux", "amd64"), base64.StdEncoding.EncodeToString(otherPublicKey), true},
+		{"missing key", "idk 1.1.0", "1.1.0", sha256Hex, sign("1.1.0", "linux", "amd64"), "", true},
+		{"invalid signature", "idk 1.1.0", "1.1.0", sha256Hex, "not base64!", key, true},
+		{"missing signature", "idk 1.1.0", "1.1.0", sha256Hex, "", key, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := VerifyReleaseBinary([]byte(test.data), test.version, "linux", "amd64", test.sha256Hex, test.signature, test.publicKey)
+			if (err != nil) != test.wantErr {
+				t.Errorf("VerifyReleaseBinary() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2.3", "1.3.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.2.0-beta.1", "1.2.0", -1},
+		{"1.2.0", "1.2.0-beta.1", 1},
+		{"1.2.0-beta.1", "1.2.0-beta.2", -1},
+		{"1.0.0", "dev", 1},
+	}
+
+	for _, test := range tests {
+		if got := CompareVersions(test.a, test.b); got != test.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// verifyOutputTail is how much of the output of a verification command is kept for the debug flow
+const verifyOutputTail = 4096
+
+// VerifyOptions control how RunVerifyCommand decides that a command passed
+type VerifyOptions struct {
+	// Timeout stops the command, 0 means no timeout
+	Timeout time.Duration
+	// LongRunning commands like servers pass once they are ready instead of when they exit.
+	// Without ReadyPort or ReadyLog they pass if they are still running at the timeout.
+	LongRunning bool
+	// ReadyPort is a local port the command listens on once it is ready
+	ReadyPort int
+	// ReadyLog is a line the command prints once it is ready
+	ReadyLog string
+}
+
+// tailWriter keeps the end of everything written to it and reports when ready appears in it
+type tailWriter struct {
+	mutex sync.Mutex
+	data  []byte
+	ready string
+	found chan struct{}
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.data = append(w.data, p...)
+	if w.ready != "" && strings.Contains(string(w.data), w.ready) {
+		close(w.found)
+		w.ready = ""
+	}
+	if len(w.data) > verifyOutputTail {
+		w.data = w.data[len(w.data)-verifyOutputTail:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return string(w.data)
+}
+
+// RunVerifyCommand runs a build, test or run command of a project, showing its output, and returns the
+// end of the output. Long-running commands are stopped once they are ready.
+func RunVerifyCommand(commandStr string, options VerifyOptions) (string, error) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		return "", fmt.Errorf("Unsupported platform")
+	}
+
+	// a port that already answers would pass the readiness check before the command even started
+	if options.ReadyPort > 0 && isPortInUse(options.ReadyPort) {
+		return "", fmt.Errorf("port %d is already in use, stop whatever listens on it and verify again", options.ReadyPort)
+	}
+
+	tail := &tailWriter{ready: options.ReadyLog, found: make(chan struct{})}
+	cmd := exec.Command("/bin/sh", "-c", commandStr)
+	cmd.Stdout = io.MultiWriter(os.Stdout, tail)
+	cmd.Stderr = io.MultiWriter(os.Stderr, tail)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	restoreTerminal := useOwnProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		restoreTerminal()
+		return "", err
+	}
+	defer restoreTerminal()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var timeout <-chan time.Time
+	if options.Timeout > 0 {
+		timer := time.NewTimer(options.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var portCheck <-chan time.Time
+	if options.ReadyPort > 0 {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+		portCheck = ticker.C
+	}
+
+	// stop ends the command once it is ready or timed out and returns err as its result
+	stop := func(err error) (string, error) {
+		_ = signalProcessGroup(cmd, syscall.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(scriptKillGracePeriod):
+			_ = signalProcessGroup(cmd, syscall.SIGKILL)
+			<-done
+		}
+		return tail.String(), err
+	}
+
+	for {
+		select {
+		case err := <-done:
+			if err == nil && options.LongRunning && (options.ReadyPort > 0 || options.ReadyLog != "") {
+				return tail.String(), fmt.Errorf("exited before it was ready")
+			}
+			return tail.String(), err
+		case sig := <-signals:
+			_ = signalProcessGroup(cmd, sig.(syscall.Signal))
+		case <-tail.found:
+			return stop(nil)
+		case <-portCheck:
+			if isPortInUse(options.ReadyPort) {
+				return stop(nil)
+			}
+		case <-timeout:
+			if options.LongRunning && options.ReadyPort == 0 && options.ReadyLog == "" {
+				// nothing tells when a server is ready, still running is the best sign
+				return stop(nil)
+			}
+			return stop(fmt.Errorf("timed out after %s", options.Timeout))
+		}
+	}
+}
+
+// isPortInUse checks if something accepts connections on the local port
+func isPortInUse(port int) bool {
+	connection, err := net.DialTimeout("tcp", fmt.Sprintf("localhost:%d", port), time.Second)
+	if err != nil {
+		return false
+	}
+	connection.Close()
+	return true
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunVerifyCommand(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	usedPort := listener.Addr().(*net.TCPAddr).Port
+
+	tests := []struct {
+		name    string
+		command string
+		options VerifyOptions
+		// wantErr is part of the expected error, "" means the command must pass
+		wantErr string
+	}{
+		{"exits successfully", "true", VerifyOptions{Timeout: 5 * time.Second}, ""},
+		{"exits with an error", "exit 3", VerifyOptions{Timeout: 5 * time.Second}, "exit status 3"},
+		{"times out", "sleep 5", VerifyOptions{Timeout: 100 * time.Millisecond}, "timed out"},
+		{"ready log", "echo listening; sleep 5", VerifyOptions{Timeout: 5 * time.Second, LongRunning: true, ReadyLog: "listening"}, ""},
+		{"still running without ready signs", "sleep 5", VerifyOptions{Timeout: 100 * time.Millisecond, LongRunning: true}, ""},
+		{"exits before it is ready", "true", VerifyOptions{Timeout: 5 * time.Second, LongRunning: true, ReadyLog: "listening"}, "exited before it was ready"},
+		{"ready port already in use", "sleep 5", VerifyOptions{Timeout: 5 * time.Second, LongRunning: true, ReadyPort: usedPort}, "already in use"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := RunVerifyCommand(test.command, test.options)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("RunVerifyCommand(%q) error = %v", test.command, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("RunVerifyCommand(%q) error = %v, want %q", test.command, err, test.wantErr)
+			}
+		})
+	}
+}
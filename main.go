@@ -2,90 +2,817 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/alexflint/go-arg"
 
 	"github.com/rishijash/idk_terminal/configs"
 	"github.com/rishijash/idk_terminal/internal/handler"
+	"github.com/rishijash/idk_terminal/internal/output"
 	"github.com/rishijash/idk_terminal/internal/utils"
 )
 
+// command is a subcommand of idk. Arguments that don't start with the name of a command are a prompt.
+type command struct {
+	name string
+	help string
+	// args is the go-arg struct of the command, read by `idk completion`
+	args any
+	// matches decides if the arguments after the name are meant for the command,
+	// so prompts starting with the same word like `idk setup nginx` still reach the backend
+	matches func(args []string) bool
+	run     func(ctx context.Context, args []string) int
+}
+
+func commands() []command {
+	return []command{
+		{"ask", "turn a prompt into a command, script or plan, like `idk <prompt>`", &rootArgs{}, always, runAsk},
+		{"debug", "run a command and explain why it failed", &debugArgs{}, always, runDebug},
+		{"setup", "help you setup the project in this folder or clone one from git", &setupArgs{}, isSetupCommand, runSetup},
+		{"auth", "login, logout and show who is logged in", &authArgs{}, flagsOrSubcommand("login", "logout", "status"), runAuth},
+		{"self", "update idk itself or roll the update back", &selfArgs{}, flagsOrSubcommand("update", "rollback"), runSelf},
+		{"version", "print the version of idk and check for updates", &versionArgs{}, flagsOrSubcommand(), runVersion},
+		{"doctor", "check the environment idk depends on and show how to fix problems", &doctorArgs{}, flagsOrSubcommand(), runDoctor},
+		{"config", "show and change settings of ~/.idk/config.json", &configArgs{}, flagsOrSubcommand("show", "path", "get", "set"), runConfig},
+		{"undo", "restore the files changed by a command idk ran", &undoArgs{}, isUndoCommand, runUndo},
+		{"scripts", "manage saved scripts", &scriptsArgs{}, flagsOrSubcommand("list", "show", "run", "rm", "sync", "clone"), runScripts},
+		{"completion", "print a shell completion script", &completionArgs{}, isCompletionCommand, runCompletion},
+	}
+}
+
 func main() {
 	ctx := context.Background()
+	args := legacyArgs(os.Args[1:])
+
+	if len(args) > 1 && args[0] == "__complete" {
+		runComplete(ctx, args[1], args[2:])
+		return
+	}
+	if len(args) > 0 {
+		for _, command := range commands() {
+			if args[0] == command.name && command.matches(args[1:]) {
+				os.Exit(command.run(ctx, args[1:]))
+			}
+		}
+	}
+	os.Exit(runPrompt(ctx, "idk", args))
+}
+
+func always(args []string) bool {
+	return true
+}
+
+// flagsOrSubcommand matches a command without arguments, with flags or with one of its subcommands
+func flagsOrSubcommand(subcommands ...string) func(args []string) bool {
+	return func(args []string) bool {
+		if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+			return true
+		}
+		for _, subcommand := range subcommands {
+			if args[0] == subcommand {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// legacyArgs rewrites the flags idk used before it had subcommands, like `idk --login`, to their subcommand
+func legacyArgs(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	replacements := map[string][]string{
+		"--login":  {"auth", "login"},
+		"--logout": {"auth", "logout"},
+		"--setup":  {"setup"},
+		"--update": {"self", "update"},
+		"--debug":  {"debug"},
+	}
+	flag, value, hasValue := strings.Cut(args[0], "=")
+	replacement, ok := replacements[flag]
+	if !ok {
+		return args
+	}
+	output.Warnln(fmt.Sprintf("`idk %s` is deprecated, use `idk %s`", flag, strings.Join(replacement, " ")))
+
+	rewritten := append([]string{}, replacement...)
+	if hasValue {
+		rewritten = append(rewritten, value)
+	}
+	return append(rewritten, args[1:]...)
+}
+
+// parseArgs parses the arguments of a command into dest. Help exits with 0 and invalid arguments with handler.ExitUsage.
+func parseArgs(program string, dest any, rawArgs []string, epilogue string) *arg.Parser {
+	parser, err := arg.NewParser(arg.Config{Program: program}, dest)
+	if err != nil {
+		output.Errorln(err.Error())
+		os.Exit(handler.ExitError)
+	}
+	err = parser.Parse(rawArgs)
+	if err == arg.ErrVersion {
+		output.Println(configs.Version)
+		os.Exit(handler.ExitOk)
+	}
+	if err == arg.ErrHelp {
+		parser.WriteHelp(os.Stdout)
+		if epilogue != "" {
+			output.Println("\n" + epilogue)
+		}
+		os.Exit(handler.ExitOk)
+	}
+	if err != nil {
+		usageError(parser, err.Error())
+	}
+	return parser
+}
+
+// usageError prints the usage of the command with msg and exits with handler.ExitUsage
+func usageError(parser *arg.Parser, msg string) {
+	parser.WriteUsage(os.Stderr)
+	output.Errorln("error: " + msg)
+	os.Exit(handler.ExitUsage)
+}
 
-	var args struct {
-		Prompt       []string `arg:"positional" help:"prompt in plain english to execute terminal commands or scripts"`
-		Login        bool     `arg:"--login" help:"login to idk cli"`
-		Logout       bool     `arg:"--logout" help:"logout from idk cli"`
-		Readme       string   `arg:"--readme" help:"path of your script's readme file to use with prompt"`
-		Debug        string   `arg:"--debug" help:"debug the command with AI"`
-		SetupProject bool     `arg:"--setup" help:"help you setup your project"`
-		Update       bool     `arg:"--update" help:"update idk to the latest version"`
+// loadUserConfig loads ~/.idk/config.json and reports invalid settings to the user
+func loadUserConfig() (*configs.UserConfig, bool) {
+	userConfig, err := configs.LoadUserConfig()
+	if err != nil {
+		output.Errorln(fmt.Sprintf("Invalid config file %s: %s", configs.UserConfigPath(), err))
+		return nil, false
 	}
-	arg.MustParse(&args)
+	if err := validateUserConfig(userConfig); err != nil {
+		output.Errorln(fmt.Sprintf("Invalid config file %s: %s", configs.UserConfigPath(), err))
+		return nil, false
+	}
+	if userConfig.ScriptLibraryDir == "" {
+		userConfig.ScriptLibraryDir = utils.DefaultScriptLibraryDir()
+	}
+	return userConfig, true
+}
 
+func validateUserConfig(userConfig *configs.UserConfig) error {
+	if !utils.IsValidRisk(userConfig.MaxAutoConfirmRisk) {
+		return fmt.Errorf("invalid maxAutoConfirmRisk `%s`. Use low, medium or high", userConfig.MaxAutoConfirmRisk)
+	}
+	for name, template := range userConfig.Templates {
+		actionType := strings.ToUpper(template.ActionType)
+		if actionType != "" && actionType != "COMMAND" && actionType != "SCRIPT" && actionType != "PLAN" {
+			return fmt.Errorf("invalid actionType `%s` of template %s. Use COMMAND, SCRIPT or PLAN", template.ActionType, name)
+		}
+	}
+	if !channelRegex.MatchString(userConfig.UpdateChannel) {
+		return fmt.Errorf("invalid updateChannel `%s`. Use stable or beta", userConfig.UpdateChannel)
+	}
+	return output.SetTheme(userConfig.Theme)
+}
+
+var channelRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_\-]*$`)
+
+// loadAppConfig loads the backend settings shipped with idk
+func loadAppConfig(options handler.Options) (*configs.Config, bool) {
 	appConfigs, err := configs.LoadConfig()
 	if err != nil {
-		println("Error running the script. Please try again!")
-		return
+		options.PrintError("internal_error", "Error running the script. Please try again!")
+		return nil, false
 	}
+	return appConfigs, true
+}
 
-	loginHandler := handler.NewLoginHandler(appConfigs)
-	debugHandler := handler.NewDebugHandler(appConfigs)
-	runHandler := handler.NewRunHandler(appConfigs)
-	promptHandler := handler.NewPromptHandler(appConfigs)
+// requireLogin returns an error telling users that are not logged in to login
+func requireLogin(ctx context.Context, appConfigs *configs.Config) error {
+	err := handler.NewLoginHandler(appConfigs).HandleLoginVerification(ctx)
+	if err != nil {
+		return handler.NewError("not_logged_in", "You are not logged in. Please login first", "Command: `idk auth login`")
+	}
+	return nil
+}
 
-	prompt := strings.Join(args.Prompt, " ")
+// finish reports the error returned by a handler and returns the exit code for it
+func finish(options handler.Options, err error) int {
+	if err != nil {
+		options.ReportError(err)
+	}
+	return handler.ExitCode(err)
+}
 
-	if args.Update {
-		utils.RunCommand("curl -o- https://idk-cli.github.io/scripts/install.sh | bash")
-		return
+func validateOutput(parser *arg.Parser, outputFormat string) {
+	if outputFormat != handler.OutputText && outputFormat != handler.OutputJson {
+		usageError(parser, "--output must be either text or json")
 	}
+}
+
+// rootArgs are the arguments of `idk <prompt>` and `idk ask <prompt>`, the `complete` tags are used by `idk completion`
+type rootArgs struct {
+	Prompt    []string      `arg:"positional" complete:"prompt" help:"prompt in plain english to execute terminal commands or scripts"`
+	Readme    string        `arg:"--readme" complete:"file" help:"path of your script's readme file to use with prompt"`
+	Output    string        `arg:"--output" default:"text" complete:"text,json" help:"output format: text or json (json never asks questions)"`
+	Yes       bool          `arg:"--yes" help:"answer yes to every confirmation, up to the maxAutoConfirmRisk set in ~/.idk/config.json"`
+	YesReally bool          `arg:"--yes-really" help:"like --yes, but also for actions above maxAutoConfirmRisk"`
+	PrintOnly bool          `arg:"--print-only" help:"only print the generated command or script on stdout"`
+	Copy      bool          `arg:"--copy" help:"copy the generated command or script to the clipboard without running it"`
+	Strict    bool          `arg:"--strict" help:"run generated shell scripts with set -euo pipefail"`
+	Timeout   time.Duration `arg:"--timeout" help:"stop generated scripts after this duration, e.g. 30s or 5m"`
+	Sandbox   bool          `arg:"--sandbox" help:"run generated commands in a sandbox and review file changes before applying them"`
+	Tui       bool          `arg:"--tui" help:"answer prompts in a full-screen session with panes for the command, its output and history. idk debug and idk setup keep the line-based flow"`
+}
+
+// Version adds --version to `idk`
+func (rootArgs) Version() string {
+	return configs.Version
+}
+
+// commandsHelp lists the subcommands below the help of `idk`
+func commandsHelp() string {
+	lines := []string{"Commands:"}
+	for _, command := range commands() {
+		lines = append(lines, fmt.Sprintf("  %-12s %s", command.name, command.help))
+	}
+	lines = append(lines, "", "Run `idk <command> --help` for the arguments of a command.")
+	lines = append(lines, "", "Exit codes:",
+		fmt.Sprintf("  %d  success", handler.ExitOk),
+		fmt.Sprintf("  %d  error", handler.ExitError),
+		fmt.Sprintf("  %d  invalid arguments or input", handler.ExitUsage),
+		fmt.Sprintf("  %d  not logged in or login expired", handler.ExitNotLoggedIn),
+		fmt.Sprintf("  %d  daily quota reached", handler.ExitQuota),
+		fmt.Sprintf("  %d  backend error", handler.ExitBackend),
+		fmt.Sprintf("  %d  cancelled, including actions --yes refused to confirm", handler.ExitCancelled),
+		"  A command or script run by idk that fails passes its own exit code through.")
+	return strings.Join(lines, "\n")
+}
 
-	if args.Login {
-		err := loginHandler.HandleLogin(ctx)
+func runAsk(ctx context.Context, rawArgs []string) int {
+	return runPrompt(ctx, "idk ask", rawArgs)
+}
+
+func runPrompt(ctx context.Context, program string, rawArgs []string) int {
+	var args rootArgs
+	epilogue := ""
+	if program == "idk" {
+		epilogue = commandsHelp()
+	}
+	parser := parseArgs(program, &args, rawArgs, epilogue)
+
+	validateOutput(parser, args.Output)
+	exclusiveModes := 0
+	for _, enabled := range []bool{args.Yes || args.YesReally, args.PrintOnly, args.Copy, args.Output == handler.OutputJson} {
+		if enabled {
+			exclusiveModes++
+		}
+	}
+	if exclusiveModes > 1 {
+		usageError(parser, "--yes, --print-only, --copy and --output json can not be combined")
+	}
+	if args.Tui && exclusiveModes > 0 {
+		usageError(parser, "--tui can not be combined with --yes, --print-only, --copy or --output json")
+	}
+	if len(args.Prompt) == 0 && !args.Tui {
+		parser.WriteHelp(os.Stderr)
+		if epilogue != "" {
+			output.Errorln("\n" + epilogue)
+		}
+		return handler.ExitUsage
+	}
+	if args.Readme != "" {
+		if _, err := os.Stat(args.Readme); err != nil {
+			usageError(parser, "invalid README file path "+args.Readme)
+		}
+	}
+
+	userConfig, ok := loadUserConfig()
+	if !ok {
+		return handler.ExitError
+	}
+
+	var err error
+	sandboxBackend := ""
+	if args.Sandbox {
+		sandboxBackend, err = utils.DetectSandboxBackend()
 		if err != nil {
-			println("Failed to Sign In With Google. Please try again!")
-			return
+			output.Errorln(err.Error())
+			return handler.ExitError
 		}
-		println("Login Successful")
-		println("Try: `idk <your prompt>`")
-		println("Learn more :`idk -h`")
-		return
 	}
 
-	if args.Logout {
-		_ = loginHandler.HandleLogout(ctx)
-		println("Logout Successful")
-		return
+	options := handler.Options{
+		OutputFormat:       args.Output,
+		AutoConfirm:        args.Yes || args.YesReally,
+		AutoConfirmAnyRisk: args.YesReally,
+		MaxAutoConfirmRisk: userConfig.MaxAutoConfirmRisk,
+		PrintOnly:          args.PrintOnly,
+		CopyOnly:           args.Copy,
+		StrictScripts:      args.Strict || userConfig.StrictScripts,
+		ScriptTimeout:      args.Timeout,
+		SandboxBackend:     sandboxBackend,
+		SandboxImage:       userConfig.SandboxImage,
+		ScriptLibraryDir:   userConfig.ScriptLibraryDir,
 	}
 
-	if args.Readme != "" {
-		_, err := os.Stat(args.Readme)
+	appConfigs, ok := loadAppConfig(options)
+	if !ok {
+		return handler.ExitError
+	}
+	promptHandler := handler.NewPromptHandler(appConfigs, options)
+
+	// templates with a cached command don't need the backend, so they are handled before the login check
+	if len(args.Prompt) > 0 && strings.HasPrefix(args.Prompt[0], "@") {
+		name := args.Prompt[0][1:]
+		template, ok := userConfig.Templates[name]
+		if !ok {
+			return finish(options, handler.NewError("invalid_input", fmt.Sprintf("No template named @%s", name), fmt.Sprintf("Define templates in %s", configs.UserConfigPath())))
+		}
+		return finish(options, promptHandler.HandleTemplate(name, template, args.Prompt[1:], args.Readme))
+	}
+
+	if err := requireLogin(ctx, appConfigs); err != nil {
+		return finish(options, err)
+	}
+
+	prompt := strings.Join(args.Prompt, " ")
+	if args.Tui {
+		return finish(options, handler.NewTuiHandler(appConfigs, options).HandleTui(ctx, prompt))
+	}
+
+	return finish(options, promptHandler.HandlePrompt(prompt, args.Readme))
+}
+
+type debugArgs struct {
+	Command   []string `arg:"positional,required" help:"command to run and debug, quote it or put it after -- if it has flags or shell syntax like pipes"`
+	Output    string   `arg:"--output" default:"text" complete:"text,json" help:"output format: text or json (json runs the command without asking, up to the maxAutoConfirmRisk set in ~/.idk/config.json)"`
+	Yes       bool     `arg:"--yes" help:"run the command without asking, up to the maxAutoConfirmRisk set in ~/.idk/config.json"`
+	YesReally bool     `arg:"--yes-really" help:"like --yes, but also for commands above maxAutoConfirmRisk"`
+}
+
+func runDebug(ctx context.Context, rawArgs []string) int {
+	var args debugArgs
+	parser := parseArgs("idk debug", &args, rawArgs, "")
+	validateOutput(parser, args.Output)
+
+	userConfig, ok := loadUserConfig()
+	if !ok {
+		return handler.ExitError
+	}
+	options := handler.Options{
+		OutputFormat:       args.Output,
+		AutoConfirm:        args.Yes || args.YesReally,
+		AutoConfirmAnyRisk: args.YesReally,
+		MaxAutoConfirmRisk: userConfig.MaxAutoConfirmRisk,
+	}
+	appConfigs, ok := loadAppConfig(options)
+	if !ok {
+		return handler.ExitError
+	}
+	if err := requireLogin(ctx, appConfigs); err != nil {
+		return finish(options, err)
+	}
+
+	return finish(options, handler.NewDebugHandler(appConfigs, options).HandleCommandDebug(ctx, strings.Join(args.Command, " ")))
+}
+
+// isSetupCommand checks if args are empty, `--flags` or start with a git url,
+// so prompts like `idk setup a redis cluster` still reach the backend
+func isSetupCommand(args []string) bool {
+	return len(args) == 0 || strings.HasPrefix(args[0], "-") || utils.IsGitUrl(args[0])
+}
+
+type setupArgs struct {
+	Url       string `arg:"positional" help:"git url or path of a repository to clone and setup instead of this folder"`
+	Dir       string `arg:"--dir" help:"directory to clone the repository into, defaults to its name"`
+	Ref       string `arg:"--ref" help:"branch, tag or commit to check out after cloning"`
+	Resume    bool   `arg:"--resume" help:"continue the last setup of this project from the step it stopped at"`
+	Verify    bool   `arg:"--verify" help:"build, test and start the project setup last time and write a setup report"`
+	Export    string `arg:"--export" complete:"script,makefile,devcontainer,contributing" help:"write the setup steps as setup.sh, a make setup target, a devcontainer or a CONTRIBUTING.md section instead of running them"`
+	Output    string `arg:"--output" default:"text" complete:"text,json" help:"output format: text or json (json prints the setup commands without running them)"`
+	Yes       bool   `arg:"--yes" help:"answer yes to every confirmation, up to the maxAutoConfirmRisk set in ~/.idk/config.json"`
+	YesReally bool   `arg:"--yes-really" help:"like --yes, but also for actions above maxAutoConfirmRisk"`
+	PrintOnly bool   `arg:"--print-only" help:"only print the setup commands on stdout"`
+	Copy      bool   `arg:"--copy" help:"copy the setup commands to the clipboard without running them"`
+}
+
+func runSetup(ctx context.Context, rawArgs []string) int {
+	var args setupArgs
+	parser := parseArgs("idk setup", &args, rawArgs, "")
+	if args.Export != "" && !slices.Contains(utils.SetupExportFormats, args.Export) {
+		usageError(parser, "--export must be one of "+strings.Join(utils.SetupExportFormats, ", "))
+	}
+	modes := 0
+	for _, enabled := range []bool{args.Resume, args.Verify, args.Export != ""} {
+		if enabled {
+			modes++
+		}
+	}
+	if modes > 1 {
+		usageError(parser, "--resume, --verify and --export can not be combined")
+	}
+	if args.Url == "" && (args.Dir != "" || args.Ref != "") {
+		usageError(parser, "--dir and --ref need a git url")
+	}
+	if args.Url != "" && (args.Resume || args.Verify) {
+		usageError(parser, "--resume and --verify work in the folder of a setup, not with a git url")
+	}
+	if strings.HasPrefix(args.Ref, "-") {
+		usageError(parser, "--ref must be a branch, tag or commit")
+	}
+	validateOutput(parser, args.Output)
+	exclusiveModes := 0
+	for _, enabled := range []bool{args.Yes || args.YesReally, args.PrintOnly, args.Copy, args.Output == handler.OutputJson} {
+		if enabled {
+			exclusiveModes++
+		}
+	}
+	if exclusiveModes > 1 {
+		usageError(parser, "--yes, --print-only, --copy and --output json can not be combined")
+	}
+	if modes > 0 && (args.PrintOnly || args.Copy || args.Output == handler.OutputJson) {
+		usageError(parser, "--print-only, --copy and --output json can not be combined with --resume, --verify or --export")
+	}
+
+	userConfig, ok := loadUserConfig()
+	if !ok {
+		return handler.ExitError
+	}
+	options := handler.Options{
+		OutputFormat:       args.Output,
+		AutoConfirm:        args.Yes || args.YesReally,
+		AutoConfirmAnyRisk: args.YesReally,
+		MaxAutoConfirmRisk: userConfig.MaxAutoConfirmRisk,
+		PrintOnly:          args.PrintOnly,
+		CopyOnly:           args.Copy,
+		StrictScripts:      userConfig.StrictScripts,
+		ScriptLibraryDir:   userConfig.ScriptLibraryDir,
+	}
+	appConfigs, ok := loadAppConfig(options)
+	if !ok {
+		return handler.ExitError
+	}
+	if err := requireLogin(ctx, appConfigs); err != nil {
+		return finish(options, err)
+	}
+
+	if args.Url != "" {
+		return finish(options, handler.NewRunHandler(appConfigs, options).HandleSetupFromGit(ctx, args.Url, args.Dir, args.Ref, args.Export))
+	}
+	return finish(options, handler.NewRunHandler(appConfigs, options).HandleSetupProject(ctx, args.Resume, args.Export, args.Verify))
+}
+
+type authArgs struct {
+	Login  *struct{} `arg:"subcommand:login" help:"sign in with google"`
+	Logout *struct{} `arg:"subcommand:logout" help:"remove the saved credentials"`
+	Status *struct{} `arg:"subcommand:status" help:"show who is logged in"`
+}
+
+func runAuth(ctx context.Context, rawArgs []string) int {
+	var args authArgs
+	parser := parseArgs("idk auth", &args, rawArgs, "")
+
+	options := handler.Options{OutputFormat: handler.OutputText}
+	appConfigs, ok := loadAppConfig(options)
+	if !ok {
+		return handler.ExitError
+	}
+	loginHandler := handler.NewLoginHandler(appConfigs)
+
+	switch {
+	case args.Login != nil:
+		if err := loginHandler.HandleLogin(ctx); err != nil {
+			output.Errorln("Failed to Sign In With Google. Please try again!")
+			return handler.ExitError
+		}
+		output.Println("Login Successful")
+		output.Println("Try: `idk <your prompt>`")
+		output.Println("Learn more :`idk -h`")
+	case args.Logout != nil:
+		_ = loginHandler.HandleLogout(ctx)
+		output.Println("Logout Successful")
+	case args.Status != nil:
+		claims, err := loginHandler.HandleLoginStatus(ctx)
 		if err != nil {
-			println("Invalid README file path")
-			return
+			output.Println("Not logged in. Login with `idk auth login`")
+			return handler.ExitNotLoggedIn
+		}
+		if claims.Email != "" {
+			output.Println("Logged in as " + claims.Email)
+		} else {
+			output.Println("Logged in")
+		}
+		if !claims.ExpiresAt.IsZero() {
+			if claims.ExpiresAt.Before(time.Now()) {
+				output.Warnln("Your login expired on " + claims.ExpiresAt.Format(time.RFC1123) + ". Login again with `idk auth login`")
+				return handler.ExitNotLoggedIn
+			}
+			output.Println("Login expires on " + claims.ExpiresAt.Format(time.RFC1123))
 		}
+	default:
+		parser.WriteHelp(os.Stderr)
+		return handler.ExitUsage
 	}
+	return handler.ExitOk
+}
 
-	err = loginHandler.HandleLoginVerification(ctx)
-	if err != nil {
-		println("You are not logged in. Please login first")
-		println("Command: `idk --login`")
-		return
+type selfUpdateCmd struct {
+	Channel string `arg:"--channel" complete:"stable,beta" help:"release channel to update from, defaults to updateChannel in ~/.idk/config.json"`
+	Version string `arg:"--version" help:"install this version instead of the latest one, older versions are installed after asking"`
+	Yes     bool   `arg:"--yes" help:"update without asking"`
+}
+
+type selfArgs struct {
+	Update   *selfUpdateCmd `arg:"subcommand:update" help:"update idk to the latest version"`
+	Rollback *struct{}      `arg:"subcommand:rollback" help:"go back to the version replaced by the last update"`
+}
+
+func runSelf(ctx context.Context, rawArgs []string) int {
+	var args selfArgs
+	parser := parseArgs("idk self", &args, joinVersionValue(rawArgs), "")
+	if args.Update == nil && args.Rollback == nil {
+		parser.WriteHelp(os.Stderr)
+		return handler.ExitUsage
 	}
 
-	if args.Debug != "" {
-		debugHandler.HandleCommandDebug(ctx, args.Debug)
-		return
+	userConfig, ok := loadUserConfig()
+	if !ok {
+		return handler.ExitError
+	}
+	options := handler.Options{OutputFormat: handler.OutputText, MaxAutoConfirmRisk: userConfig.MaxAutoConfirmRisk}
+	appConfigs, ok := loadAppConfig(options)
+	if !ok {
+		return handler.ExitError
 	}
 
-	if args.SetupProject {
-		runHandler.HandleSetupProject(ctx)
-		return
+	if args.Rollback != nil {
+		return finish(options, handler.NewUpdateHandler(appConfigs, options).HandleRollback(ctx))
+	}
+	channel := args.Update.Channel
+	if channel == "" {
+		channel = userConfig.UpdateChannel
+	}
+	options.AutoConfirm = args.Update.Yes
+	return finish(options, handler.NewUpdateHandler(appConfigs, options).HandleSelfUpdate(ctx, channel, args.Update.Version))
+}
+
+// joinVersionValue rewrites `--version 1.2.3` to `--version=1.2.3`, go-arg takes a bare --version as a request
+// for the version of idk
+func joinVersionValue(rawArgs []string) []string {
+	var joined []string
+	for i := 0; i < len(rawArgs); i++ {
+		if rawArgs[i] == "--version" && i+1 < len(rawArgs) && !strings.HasPrefix(rawArgs[i+1], "-") {
+			joined = append(joined, "--version="+rawArgs[i+1])
+			i++
+			continue
+		}
+		joined = append(joined, rawArgs[i])
+	}
+	return joined
+}
+
+type versionArgs struct {
+	Channel string `arg:"--channel" complete:"stable,beta" help:"release channel to check for updates, defaults to updateChannel in ~/.idk/config.json"`
+	Output  string `arg:"--output" default:"text" complete:"text,json" help:"output format: text or json"`
+}
+
+func runVersion(ctx context.Context, rawArgs []string) int {
+	var args versionArgs
+	parser := parseArgs("idk version", &args, rawArgs, "")
+	validateOutput(parser, args.Output)
+
+	userConfig, ok := loadUserConfig()
+	if !ok {
+		return handler.ExitError
+	}
+	options := handler.Options{OutputFormat: args.Output}
+	appConfigs, ok := loadAppConfig(options)
+	if !ok {
+		return handler.ExitError
+	}
+
+	channel := args.Channel
+	if channel == "" {
+		channel = userConfig.UpdateChannel
+	}
+	return finish(options, handler.NewUpdateHandler(appConfigs, options).HandleVersion(ctx, channel))
+}
+
+type doctorArgs struct {
+	Output string `arg:"--output" default:"text" complete:"text,json" help:"output format: text or json, for bug reports"`
+}
+
+func runDoctor(ctx context.Context, rawArgs []string) int {
+	var args doctorArgs
+	parser := parseArgs("idk doctor", &args, rawArgs, "")
+	validateOutput(parser, args.Output)
+
+	// an invalid config is one of the problems doctor reports, so it isn't loaded with loadUserConfig
+	options := handler.Options{OutputFormat: args.Output}
+	appConfigs, ok := loadAppConfig(options)
+	if !ok {
+		return handler.ExitError
+	}
+	return finish(options, handler.NewDoctorHandler(appConfigs, options).HandleDoctor(ctx, validateUserConfig))
+}
+
+type configGetCmd struct {
+	Key string `arg:"positional,required" complete:"maxAutoConfirmRisk,strictScripts,sandboxImage,scriptLibraryDir,theme,updateChannel,templates" help:"name of the setting"`
+}
+
+type configSetCmd struct {
+	Key   string `arg:"positional,required" complete:"maxAutoConfirmRisk,strictScripts,sandboxImage,scriptLibraryDir,theme,updateChannel" help:"name of the setting"`
+	Value string `arg:"positional,required" help:"new value of the setting"`
+}
+
+type configArgs struct {
+	Show *struct{}     `arg:"subcommand:show" help:"print every setting, including defaults"`
+	Path *struct{}     `arg:"subcommand:path" help:"print the location of the config file"`
+	Get  *configGetCmd `arg:"subcommand:get" help:"print one setting"`
+	Set  *configSetCmd `arg:"subcommand:set" help:"change one setting, templates are edited in the file"`
+}
+
+func runConfig(ctx context.Context, rawArgs []string) int {
+	var args configArgs
+	parseArgs("idk config", &args, rawArgs, "")
+
+	options := handler.Options{OutputFormat: handler.OutputText}
+	if args.Path != nil {
+		handler.NewConfigHandler(nil, options).HandlePath(ctx)
+		return handler.ExitOk
+	}
+	if args.Set != nil {
+		// the config is validated after the change, so `config set` can fix an invalid file
+		return finish(options, handler.NewConfigHandler(nil, options).HandleSet(ctx, args.Set.Key, args.Set.Value, validateUserConfig))
+	}
+
+	userConfig, ok := loadUserConfig()
+	if !ok {
+		return handler.ExitError
+	}
+	configHandler := handler.NewConfigHandler(userConfig, options)
+	if args.Get != nil {
+		return finish(options, configHandler.HandleGet(ctx, args.Get.Key))
+	}
+	configHandler.HandleShow(ctx)
+	return handler.ExitOk
+}
+
+// isUndoCommand checks if args are empty, `<id>` or `--flags`,
+// so prompts like `idk undo my last git commit` still reach the backend
+func isUndoCommand(args []string) bool {
+	return len(args) == 0 || utils.IsHistoryId(args[0]) || strings.HasPrefix(args[0], "-")
+}
+
+type undoArgs struct {
+	Id   string `arg:"positional" complete:"snapshot" help:"id of the snapshot to restore, defaults to the latest one"`
+	List bool   `arg:"--list" help:"list snapshots"`
+	Yes  bool   `arg:"--yes" help:"restore without asking"`
+}
+
+func runUndo(ctx context.Context, rawArgs []string) int {
+	var args undoArgs
+	parseArgs("idk undo", &args, rawArgs, "")
+
+	options := handler.Options{
+		OutputFormat:       handler.OutputText,
+		AutoConfirm:        args.Yes,
+		MaxAutoConfirmRisk: utils.RiskMedium,
+	}
+	undoHandler := handler.NewUndoHandler(options)
+	if args.List {
+		return finish(options, undoHandler.HandleListSnapshots(ctx))
+	}
+	return finish(options, undoHandler.HandleUndo(ctx, args.Id))
+}
+
+type scriptsListCmd struct {
+	Tag string `arg:"--tag" help:"only list scripts with this tag"`
+}
+
+type scriptsShowCmd struct {
+	Name string `arg:"positional,required" complete:"script" help:"name of the script"`
+}
+
+type scriptsRunCmd struct {
+	Name      string        `arg:"positional,required" complete:"script" help:"name of the script"`
+	Params    []string      `arg:"--param,separate" help:"parameter passed to the script as key=value, can be repeated"`
+	Strict    bool          `arg:"--strict" help:"run shell scripts with set -euo pipefail"`
+	Timeout   time.Duration `arg:"--timeout" help:"stop the script after this duration, e.g. 30s or 5m"`
+	Sandbox   bool          `arg:"--sandbox" help:"run the script in a sandbox and review file changes before applying them"`
+	Yes       bool          `arg:"--yes" help:"run the script without asking, up to the maxAutoConfirmRisk set in ~/.idk/config.json"`
+	YesReally bool          `arg:"--yes-really" help:"like --yes, but also for scripts above maxAutoConfirmRisk"`
+}
+
+type scriptsRmCmd struct {
+	Name string `arg:"positional,required" complete:"script" help:"name of the script"`
+	Yes  bool   `arg:"--yes" help:"remove without asking"`
+}
+
+type scriptsCloneCmd struct {
+	Url string `arg:"positional,required" help:"git url of a shared script library"`
+}
+
+type scriptsArgs struct {
+	List   *scriptsListCmd  `arg:"subcommand:list" help:"list saved scripts"`
+	Show   *scriptsShowCmd  `arg:"subcommand:show" help:"show a saved script"`
+	Run    *scriptsRunCmd   `arg:"subcommand:run" help:"run a saved script"`
+	Rm     *scriptsRmCmd    `arg:"subcommand:rm" help:"remove a saved script"`
+	Sync   *struct{}        `arg:"subcommand:sync" help:"pull and push a script library shared through git"`
+	Clone  *scriptsCloneCmd `arg:"subcommand:clone" help:"use a script library shared through git"`
+	Output string           `arg:"--output" default:"text" complete:"text,json" help:"output format: text or json"`
+}
+
+func runScripts(ctx context.Context, rawArgs []string) int {
+	var args scriptsArgs
+	parser := parseArgs("idk scripts", &args, rawArgs, "")
+	validateOutput(parser, args.Output)
+
+	userConfig, ok := loadUserConfig()
+	if !ok {
+		return handler.ExitError
+	}
+	options := handler.Options{
+		OutputFormat:       args.Output,
+		MaxAutoConfirmRisk: userConfig.MaxAutoConfirmRisk,
+		StrictScripts:      userConfig.StrictScripts,
+		SandboxImage:       userConfig.SandboxImage,
+		ScriptLibraryDir:   userConfig.ScriptLibraryDir,
 	}
 
-	promptHandler.HandlePrompt(prompt, args.Readme)
+	var err error
+	switch {
+	case args.Show != nil:
+		err = handler.NewScriptsHandler(options).HandleShow(ctx, args.Show.Name)
+	case args.Run != nil:
+		options.StrictScripts = options.StrictScripts || args.Run.Strict
+		options.AutoConfirm = args.Run.Yes || args.Run.YesReally
+		options.AutoConfirmAnyRisk = args.Run.YesReally
+		options.ScriptTimeout = args.Run.Timeout
+		if args.Run.Sandbox {
+			options.SandboxBackend, err = utils.DetectSandboxBackend()
+			if err != nil {
+				output.Errorln(err.Error())
+				return handler.ExitError
+			}
+		}
+		err = handler.NewScriptsHandler(options).HandleRun(ctx, args.Run.Name, args.Run.Params)
+	case args.Rm != nil:
+		options.AutoConfirm = args.Rm.Yes
+		err = handler.NewScriptsHandler(options).HandleRemove(ctx, args.Rm.Name)
+	case args.Sync != nil:
+		err = handler.NewScriptsHandler(options).HandleSync(ctx)
+	case args.Clone != nil:
+		err = handler.NewScriptsHandler(options).HandleClone(ctx, args.Clone.Url)
+	default:
+		tag := ""
+		if args.List != nil {
+			tag = args.List.Tag
+		}
+		err = handler.NewScriptsHandler(options).HandleList(ctx, tag)
+	}
+	return finish(options, err)
+}
+
+type completionArgs struct {
+	Shell string `arg:"positional,required" complete:"bash,zsh,fish" help:"shell to print the completion script for: bash, zsh or fish"`
+}
+
+// isCompletionCommand checks if args are a shell or flags,
+// so prompts like `idk completion of my build` still reach the backend
+func isCompletionCommand(args []string) bool {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return true
+	}
+	return len(args) == 1 && (args[0] == "bash" || args[0] == "zsh" || args[0] == "fish")
+}
+
+func runCompletion(ctx context.Context, rawArgs []string) int {
+	var args completionArgs
+	parseArgs("idk completion", &args, rawArgs, "Load completion in bash with `source <(idk completion bash)`, in zsh with `source <(idk completion zsh)`\nand in fish with `idk completion fish | source`")
+
+	options := handler.Options{OutputFormat: handler.OutputText}
+	return finish(options, handler.NewCompletionHandler(options).HandleCompletion(ctx, args.Shell, completionSpec()))
+}
+
+// completionSpec describes every idk command for the completion scripts
+func completionSpec() utils.CompletionCommand {
+	root := utils.CompletionCommandFromArgs("idk", "", &rootArgs{})
+	root.Flags = append(root.Flags, utils.CompletionFlag{Name: "--version", Help: "display version and exit"})
+	for _, command := range commands() {
+		root.Subcommands = append(root.Subcommands, utils.CompletionCommandFromArgs(command.name, command.help, command.args))
+	}
+	return root
+}
+
+// runComplete is called by the completion scripts to look up previous prompts, saved scripts and snapshots
+func runComplete(ctx context.Context, kind string, words []string) {
+	userConfig, err := configs.LoadUserConfig()
+	if err != nil {
+		return
+	}
+	if userConfig.ScriptLibraryDir == "" {
+		userConfig.ScriptLibraryDir = utils.DefaultScriptLibraryDir()
+	}
+	options := handler.Options{OutputFormat: handler.OutputText, ScriptLibraryDir: userConfig.ScriptLibraryDir}
+	handler.NewCompletionHandler(options).HandleComplete(ctx, kind, words, userConfig.Templates)
 }
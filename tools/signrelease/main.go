@@ -0,0 +1,76 @@
+// signrelease signs release binaries for `idk self update` and prints the release entry of the channel manifest.
+// The private key is the base64 encoded ed25519 key in RELEASE_PRIVATE_KEY. Binaries are named
+// idk_<version>_<os>_<arch>, like `make release` builds them.
+//
+//	RELEASE_PRIVATE_KEY=... go run ./tools/signrelease -version 1.2.3 dist/idk_1.2.3_*
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rishijash/idk_terminal/internal/clients"
+	"github.com/rishijash/idk_terminal/internal/utils"
+)
+
+func main() {
+	version := flag.String("version", "", "version of the release, like 1.2.3")
+	notes := flag.String("notes", "", "release notes in markdown")
+	flag.Parse()
+
+	if err := run(*version, *notes, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(version string, notes string, paths []string) error {
+	if version == "" || len(paths) == 0 {
+		return fmt.Errorf("usage: signrelease -version 1.2.3 [-notes text] binary...")
+	}
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("RELEASE_PRIVATE_KEY"))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return fmt.Errorf("RELEASE_PRIVATE_KEY must be a base64 encoded ed25519 private key")
+	}
+
+	release := clients.Release{Version: version, Date: time.Now().UTC().Format("2006-01-02"), Notes: notes}
+	prefix := fmt.Sprintf("idk_%s_", version)
+	for _, path := range paths {
+		name := filepath.Base(path)
+		osName, arch, ok := strings.Cut(strings.TrimPrefix(name, prefix), "_")
+		if !strings.HasPrefix(name, prefix) || !ok {
+			return fmt.Errorf("%s is not named %s<os>_<arch>", path, prefix)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		checksum := sha256.Sum256(data)
+		sha256Hex := hex.EncodeToString(checksum[:])
+		signature := ed25519.Sign(ed25519.PrivateKey(key), utils.ReleaseSignedMessage(version, osName, arch, sha256Hex))
+		release.Binaries = append(release.Binaries, clients.ReleaseBinary{
+			Os:        osName,
+			Arch:      arch,
+			Url:       name,
+			Sha256:    sha256Hex,
+			Signature: base64.StdEncoding.EncodeToString(signature),
+		})
+	}
+
+	data, err := json.MarshalIndent(release, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}